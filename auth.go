@@ -0,0 +1,459 @@
+package httpserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	AuthSchemeBasic  = "Basic"
+	AuthSchemeBearer = "Bearer"
+	AuthSchemeAPIKey = "APIKey"
+
+	defaultAPIKeyHeader = "X-Api-Key"
+)
+
+var (
+	ErrInvalidToken     = errors.New("httpserver: invalid token")
+	ErrTokenExpired     = errors.New("httpserver: token is expired")
+	ErrTokenNotValidYet = errors.New("httpserver: token is not valid yet")
+	ErrUnknownKey       = errors.New("httpserver: unknown signing key")
+)
+
+type principalKey struct{}
+
+// Principal authenticated identity of incoming request.
+type Principal struct {
+	// Subject user name, key owner, or `sub` claim of a token.
+	Subject string
+
+	// Scheme authentication scheme used, one of AuthScheme* constants.
+	Scheme string
+
+	// Claims token claims if authenticated with Bearer JWT, otherwise nil.
+	Claims map[string]interface{}
+}
+
+// GetPrincipal return authenticated principal stored by auth middlewares in request context.
+func GetPrincipal(r *http.Request) (*Principal, bool) {
+	p, ok := r.Context().Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+func withPrincipal(r *http.Request, p *Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc, challenge string) {
+	if challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	if handler != nil {
+		handler(w, r)
+		return
+	}
+	ResponseString(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+}
+
+// secureCompare compare 2 strings in constant time, including their length.
+func secureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// BasicAuthOpts options for HTTP Basic authentication middleware.
+type BasicAuthOpts struct {
+	// Realm sent in WWW-Authenticate header. If empty then "Restricted" is used.
+	Realm string
+
+	// Users map of user name to its password.
+	Users map[string]string
+
+	// Validate optional, custom credentials check used if user is not found in Users.
+	Validate func(user, password string) bool
+
+	// Unauthorized optional, triggered if authentication failed. If empty then default 401 response is used.
+	Unauthorized http.HandlerFunc
+}
+
+// BasicAuth middleware authenticating requests with HTTP Basic scheme.
+// Passwords are compared in constant time.
+func BasicAuth(opts *BasicAuthOpts) Middleware {
+	realm := opts.Realm
+	if realm == "" {
+		realm = "Restricted"
+	}
+	challenge := fmt.Sprintf("%s realm=%q", AuthSchemeBasic, realm)
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok {
+				unauthorized(w, r, opts.Unauthorized, challenge)
+				return
+			}
+			valid := false
+			if expected, found := opts.Users[user]; found {
+				valid = secureCompare(password, expected)
+			} else if opts.Validate != nil {
+				valid = opts.Validate(user, password)
+			}
+			if !valid {
+				unauthorized(w, r, opts.Unauthorized, challenge)
+				return
+			}
+			next(w, withPrincipal(r, &Principal{Subject: user, Scheme: AuthSchemeBasic}))
+		}
+	}
+}
+
+// APIKeyOpts options for API key authentication middleware.
+type APIKeyOpts struct {
+	// Header name holding the key. If both Header and Query are empty then "X-Api-Key" is used.
+	Header string
+
+	// Query parameter name holding the key, checked if key is not found in Header.
+	Query string
+
+	// Keys map of API key to its owner, stored as principal subject.
+	Keys map[string]string
+
+	// Validate optional, custom key check used if key is not found in Keys. Return the key owner and whether it is valid.
+	Validate func(key string) (string, bool)
+
+	// Unauthorized optional, triggered if authentication failed. If empty then default 401 response is used.
+	Unauthorized http.HandlerFunc
+}
+
+// APIKey middleware authenticating requests with API key sent in a header or query parameter.
+func APIKey(opts *APIKeyOpts) Middleware {
+	header := opts.Header
+	if header == "" && opts.Query == "" {
+		header = defaultAPIKeyHeader
+	}
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var key string
+			if header != "" {
+				key = r.Header.Get(header)
+			}
+			if key == "" && opts.Query != "" {
+				key = r.URL.Query().Get(opts.Query)
+			}
+			if key == "" {
+				unauthorized(w, r, opts.Unauthorized, "")
+				return
+			}
+			var (
+				owner string
+				valid bool
+			)
+			for k, v := range opts.Keys {
+				if secureCompare(key, k) {
+					owner, valid = v, true
+				}
+			}
+			if !valid && opts.Validate != nil {
+				owner, valid = opts.Validate(key)
+			}
+			if !valid {
+				unauthorized(w, r, opts.Unauthorized, "")
+				return
+			}
+			next(w, withPrincipal(r, &Principal{Subject: owner, Scheme: AuthSchemeAPIKey}))
+		}
+	}
+}
+
+// JWTOpts options for Bearer JWT authentication middleware.
+// Supported algorithms are HS256, RS256 and ES256.
+type JWTOpts struct {
+	// Secret shared secret to verify HS256 tokens.
+	Secret []byte
+
+	// PublicKey static *rsa.PublicKey or *ecdsa.PublicKey to verify RS256 or ES256 tokens.
+	PublicKey crypto.PublicKey
+
+	// JWKSFile path to JSON Web Key Set file. Keys are matched against token `kid` header.
+	JWKSFile string
+
+	// Issuer if not empty then token `iss` claim must match.
+	Issuer string
+
+	// Audience if not empty then token `aud` claim must contain it.
+	Audience string
+
+	// Leeway tolerated clock skew when checking `exp` and `nbf` claims.
+	Leeway time.Duration
+
+	// Unauthorized optional, triggered if authentication failed. If empty then default 401 response is used.
+	Unauthorized http.HandlerFunc
+}
+
+type jwtVerifier struct {
+	opts *JWTOpts
+	keys map[string]interface{} // kid to key
+	now  func() time.Time
+}
+
+// JWT middleware authenticating requests with Bearer JSON Web Token.
+// Verified token claims are stored in principal.
+func JWT(opts *JWTOpts) (Middleware, error) {
+	v, err := newJWTVerifier(opts)
+	if err != nil {
+		return nil, err
+	}
+	challenge := AuthSchemeBearer
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if len(auth) <= len(AuthSchemeBearer)+1 || !strings.EqualFold(auth[:len(AuthSchemeBearer)+1], AuthSchemeBearer+" ") {
+				unauthorized(w, r, opts.Unauthorized, challenge)
+				return
+			}
+			claims, err := v.verify(strings.TrimSpace(auth[len(AuthSchemeBearer)+1:]))
+			if err != nil {
+				unauthorized(w, r, opts.Unauthorized, fmt.Sprintf(`%s error="invalid_token"`, AuthSchemeBearer))
+				return
+			}
+			sub, _ := claims["sub"].(string)
+			next(w, withPrincipal(r, &Principal{Subject: sub, Scheme: AuthSchemeBearer, Claims: claims}))
+		}
+	}, nil
+}
+
+func newJWTVerifier(opts *JWTOpts) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		opts: opts,
+		keys: make(map[string]interface{}),
+		now:  time.Now,
+	}
+	if opts.JWKSFile != "" {
+		content, err := ioutil.ReadFile(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		if v.keys, err = parseJWKS(content); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Secret) == 0 && opts.PublicKey == nil && len(v.keys) == 0 {
+		return nil, errors.New("httpserver: JWT requires Secret, PublicKey or JWKSFile")
+	}
+	return v, nil
+}
+
+// verify check token signature and registered claims, return its claims.
+func (v *jwtVerifier) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, err := v.key(header.Alg, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := v.now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.opts.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.opts.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrTokenNotValidYet
+	}
+	if v.opts.Issuer != "" && claims["iss"] != v.opts.Issuer {
+		return nil, ErrInvalidToken
+	}
+	if v.opts.Audience != "" && !audienceContains(claims["aud"], v.opts.Audience) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// key pick verification key for the token, JWKS key is preferred if token has `kid` header.
+func (v *jwtVerifier) key(alg, kid string) (interface{}, error) {
+	if kid != "" {
+		if k, ok := v.keys[kid]; ok {
+			return k, nil
+		}
+	}
+	switch alg {
+	case "HS256":
+		if len(v.opts.Secret) > 0 {
+			return v.opts.Secret, nil
+		}
+	case "RS256", "ES256":
+		if v.opts.PublicKey != nil {
+			return v.opts.PublicKey, nil
+		}
+	}
+	// fallback to the only key in set if token has no `kid`.
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func verifyJWTSignature(alg string, key interface{}, signingInput string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrUnknownKey
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidToken
+		}
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrUnknownKey
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrInvalidToken
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrUnknownKey
+		}
+		if len(sig) != 64 {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+	default:
+		return fmt.Errorf("httpserver: unsupported token algorithm %q", alg)
+	}
+	return nil
+}
+
+func decodeJWTSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func audienceContains(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseJWKS parse JSON Web Key Set into map of key id to key.
+// Supported key types are RSA, EC with P-256 curve and oct.
+func parseJWKS(content []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, err
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, err
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if k.Crv != "P-256" {
+				return nil, fmt.Errorf("httpserver: unsupported curve %q in key %q", k.Crv, k.Kid)
+			}
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil {
+				return nil, err
+			}
+			y, err := base64.RawURLEncoding.DecodeString(k.Y)
+			if err != nil {
+				return nil, err
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, err
+			}
+			keys[k.Kid] = secret
+		default:
+			return nil, fmt.Errorf("httpserver: unsupported key type %q in key %q", k.Kty, k.Kid)
+		}
+	}
+	return keys, nil
+}
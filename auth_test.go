@@ -0,0 +1,192 @@
+package httpserver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func signTestJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "RS256":
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func serveAuth(m Middleware, r *http.Request) (*httptest.ResponseRecorder, *Principal) {
+	var principal *Principal
+	h := m(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = GetPrincipal(r)
+	})
+	w := httptest.NewRecorder()
+	h(newResponseWriter(w, "", ""), r)
+	return w, principal
+}
+
+func TestBasicAuth(t *testing.T) {
+	m := BasicAuth(&BasicAuthOpts{Users: map[string]string{"admin": "secret"}})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("admin", "secret")
+	if _, p := serveAuth(m, r); p == nil || p.Subject != "admin" || p.Scheme != AuthSchemeBasic {
+		t.Errorf("%s expected principal admin, returned %v", t.Name(), p)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("admin", "wrong")
+	w, p := serveAuth(m, r)
+	if p != nil || w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("%s expected 401 with challenge, returned %d", t.Name(), w.Code)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	m := APIKey(&APIKeyOpts{Header: "X-Api-Key", Query: "api_key", Keys: map[string]string{"k1": "service-a"}})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Api-Key", "k1")
+	if _, p := serveAuth(m, r); p == nil || p.Subject != "service-a" {
+		t.Errorf("%s expected principal service-a, returned %v", t.Name(), p)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?api_key=k1", nil)
+	if _, p := serveAuth(m, r); p == nil || p.Subject != "service-a" {
+		t.Errorf("%s expected principal from query, returned %v", t.Name(), p)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?api_key=k2", nil)
+	if w, _ := serveAuth(m, r); w.Code != http.StatusUnauthorized {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestJWT_HS256(t *testing.T) {
+	secret := []byte("secret")
+	m, err := JWT(&JWTOpts{Secret: secret, Issuer: "issuer", Audience: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{"sub": "user-1", "iss": "issuer", "aud": []string{"api"}, "exp": time.Now().Add(time.Minute).Unix()}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signTestJWT(t, "HS256", "", secret, claims))
+	if _, p := serveAuth(m, r); p == nil || p.Subject != "user-1" || p.Claims["iss"] != "issuer" {
+		t.Errorf("%s expected principal user-1, returned %v", t.Name(), p)
+	}
+
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signTestJWT(t, "HS256", "", secret, claims))
+	if w, _ := serveAuth(m, r); w.Code != http.StatusUnauthorized {
+		t.Errorf("%s expected expired token rejected, returned %d", t.Name(), w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signTestJWT(t, "HS256", "", []byte("other"), claims))
+	if w, _ := serveAuth(m, r); w.Code != http.StatusUnauthorized {
+		t.Errorf("%s expected invalid signature rejected, returned %d", t.Name(), w.Code)
+	}
+}
+
+func TestJWT_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := JWT(&JWTOpts{PublicKey: &key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signTestJWT(t, "RS256", "", key, map[string]interface{}{"sub": "user-2"}))
+	if _, p := serveAuth(m, r); p == nil || p.Subject != "user-2" {
+		t.Errorf("%s expected principal user-2, returned %v", t.Name(), p)
+	}
+}
+
+func TestJWT_JWKSFile(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": "ec-1",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}},
+	}
+	dir, err := ioutil.TempDir("", "httpserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	content, _ := json.Marshal(jwks)
+	ioutil.WriteFile(path, content, 0600)
+
+	m, err := JWT(&JWTOpts{JWKSFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signTestJWT(t, "ES256", "ec-1", key, map[string]interface{}{"sub": "user-3"}))
+	if _, p := serveAuth(m, r); p == nil || p.Subject != "user-3" {
+		t.Errorf("%s expected principal user-3, returned %v", t.Name(), p)
+	}
+
+	// token signed with HS256 using public key material must not be accepted.
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signTestJWT(t, "HS256", "ec-1", big.NewInt(1).Bytes(), map[string]interface{}{"sub": "user-3"}))
+	if w, _ := serveAuth(m, r); w.Code != http.StatusUnauthorized {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestJWT_NoKey(t *testing.T) {
+	if _, err := JWT(&JWTOpts{}); err == nil {
+		t.Errorf("%s expected error, returned nil", t.Name())
+	}
+}
@@ -0,0 +1,336 @@
+package httpserver
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	_router "github.com/julienschmidt/httprouter"
+)
+
+const defaultBindMaxBodySize = 10 << 20
+
+var (
+	ErrUnsupportedMediaType = errors.New("httpserver: unsupported media type")
+	ErrInvalidBody          = errors.New("httpserver: invalid request body")
+	ErrInvalidBindTarget    = errors.New("httpserver: bind target must be a non-nil pointer to struct")
+)
+
+type pathParamsKey struct{}
+
+// PathParam return value of path parameter name, e.g. "id" of route "/users/:id".
+func PathParam(r *http.Request, name string) string {
+	ps, _ := r.Context().Value(pathParamsKey{}).(_router.Params)
+	return ps.ByName(name)
+}
+
+// FieldError error of a single request field.
+type FieldError struct {
+	// Field name of the field as sent by client, e.g. json or query name.
+	Field string `json:"field"`
+
+	// Source where the field comes from, e.g. "json", "query" or "path".
+	Source string `json:"source,omitempty"`
+
+	// Code machine readable reason, the failing rule name for validation errors, e.g. "required".
+	Code string `json:"code,omitempty"`
+
+	// Param parameter of the failing rule, e.g. "3" of "min=3".
+	Param string `json:"param,omitempty"`
+
+	// Message reason of the error.
+	Message string `json:"message"`
+}
+
+// FieldErrors list of field errors, returned by Bind and Validate.
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	msgs := make([]string, 0, len(fe))
+	for _, e := range fe {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", e.Field, e.Message))
+	}
+	return "httpserver: invalid fields: " + strings.Join(msgs, "; ")
+}
+
+// BindOpts options of BindWith.
+type BindOpts struct {
+	// DisallowUnknownFields reject json and form fields not declared in dst.
+	DisallowUnknownFields bool
+
+	// MaxBodySize maximum body size in bytes. If empty then 10MB is used.
+	MaxBodySize int64
+}
+
+// Bind decode request into dst, a pointer to struct.
+// Body is decoded by its Content-Type with registered codec, see RegisterCodec, into fields tagged with `json`, `xml`
+// or `form`, codecs other than xml, e.g. yaml or msgpack, decode into fields tagged with `json`.
+// Then fields tagged with `path`, `query` and `header` are filled and converted to their types.
+// Return FieldErrors if some fields are invalid, ErrUnsupportedMediaType or ErrInvalidBody if body can't be decoded.
+func Bind(r *http.Request, dst interface{}) error {
+	return BindWith(r, dst, nil)
+}
+
+// BindWith same as Bind with options.
+// @opts: can be nil, if nil then default is used.
+func BindWith(r *http.Request, dst interface{}, opts *BindOpts) error {
+	if opts == nil {
+		opts = &BindOpts{}
+	}
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidBindTarget
+	}
+	maxBodySize := opts.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultBindMaxBodySize
+	}
+
+	var errs FieldErrors
+	if hasBody(r) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(nil, r.Body, maxBodySize)
+		}
+		bodyErrs, err := bindBody(r, dst, v.Elem(), opts.DisallowUnknownFields)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, bodyErrs...)
+	}
+
+	ps, _ := r.Context().Value(pathParamsKey{}).(_router.Params)
+	query := r.URL.Query()
+	errs = append(errs, bindValues(v.Elem(), "path", func(name string) []string {
+		for _, p := range ps {
+			if p.Key == name {
+				return []string{p.Value}
+			}
+		}
+		return nil
+	})...)
+	errs = append(errs, bindValues(v.Elem(), "query", func(name string) []string {
+		return query[name]
+	})...)
+	errs = append(errs, bindValues(v.Elem(), "header", func(name string) []string {
+		return r.Header[textproto.CanonicalMIMEHeaderKey(name)]
+	})...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	return r.ContentLength != 0 || len(r.TransferEncoding) > 0 || r.Header.Get("Content-Type") != ""
+}
+
+func bindBody(r *http.Request, dst interface{}, v reflect.Value, strict bool) (FieldErrors, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	if mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" {
+		if mediaType == "multipart/form-data" {
+			err = r.ParseMultipartForm(defaultBindMaxBodySize)
+		} else {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+		}
+		errs := bindValues(v, "form", func(name string) []string {
+			return r.PostForm[name]
+		})
+		if strict {
+			known := make(map[string]struct{})
+			for _, name := range tagNames(v.Type(), "form") {
+				known[name] = struct{}{}
+			}
+			for name := range r.PostForm {
+				if _, ok := known[name]; !ok {
+					errs = append(errs, FieldError{Field: name, Source: "form", Code: "unknown", Message: "unknown field"})
+				}
+			}
+		}
+		return errs, nil
+	}
+	c, ok := LookupCodec(mediaType)
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+	if sd, ok := c.(strictDecoder); ok && strict {
+		err = sd.DecodeStrict(r.Body, dst)
+	} else {
+		err = c.Decode(r.Body, dst)
+	}
+	if err != nil {
+		return jsonBindError(err)
+	}
+	return nil, nil
+}
+
+func jsonBindError(err error) (FieldErrors, error) {
+	var fe FieldErrors
+	if errors.As(err, &fe) {
+		return fe, nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return FieldErrors{{Field: typeErr.Field, Source: "json", Code: "type", Message: "must be " + typeErr.Type.String()}}, nil
+	}
+	// unknown field error has no dedicated type.
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		name, _ := strconv.Unquote(strings.TrimPrefix(msg, "json: unknown field "))
+		return FieldErrors{{Field: name, Source: "json", Code: "unknown", Message: "unknown field"}}, nil
+	}
+	if err == io.EOF {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+}
+
+// bindValues set fields tagged with tag from values returned by lookup, including fields of embedded structs.
+func bindValues(v reflect.Value, tag string, lookup func(name string) []string) FieldErrors {
+	var errs FieldErrors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			errs = append(errs, bindValues(fv, tag, lookup)...)
+			continue
+		}
+		name := tagName(sf, tag)
+		if name == "" || !fv.CanSet() {
+			continue
+		}
+		values := lookup(name)
+		if len(values) == 0 {
+			continue
+		}
+		if err := setField(fv, values); err != nil {
+			errs = append(errs, FieldError{Field: name, Source: tag, Code: "type", Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+func tagName(sf reflect.StructField, tag string) string {
+	name := strings.Split(sf.Tag.Get(tag), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func tagNames(t reflect.Type, tag string) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			names = append(names, tagNames(sf.Type, tag)...)
+			continue
+		}
+		if name := tagName(sf, tag); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setField convert values into field type. Slices take all values, other types take the first one.
+func setField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, value string) error {
+	if fv.Kind() == reflect.Ptr {
+		p := reflect.New(fv.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		if err := fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid value %q", value)
+		}
+		return nil
+	}
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration, e.g. 1m30s")
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("must be an RFC3339 time")
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
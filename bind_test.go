@@ -0,0 +1,122 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_router "github.com/julienschmidt/httprouter"
+)
+
+type testBindEmbedded struct {
+	Trace string `header:"X-Trace"`
+}
+
+type testBindRequest struct {
+	testBindEmbedded
+	ID      int           `path:"id"`
+	Name    string        `json:"name" form:"name" xml:"name"`
+	Age     int           `json:"age" form:"age" xml:"age"`
+	Tags    []string      `query:"tag"`
+	Limit   *uint         `query:"limit"`
+	Timeout time.Duration `query:"timeout"`
+	Active  bool          `query:"active"`
+}
+
+func testBindServe(r *http.Request, dst interface{}, opts *BindOpts) error {
+	var err error
+	router := _router.New()
+	router.Handle(r.Method, "/users/:id", f(func(w http.ResponseWriter, r *http.Request) {
+		err = BindWith(r, dst, opts)
+	}, nil))
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	return err
+}
+
+func TestBind_JSON(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/7?tag=a&tag=b&limit=10&timeout=1m&active=true", strings.NewReader(`{"name":"john","age":30}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Set("X-Trace", "abc")
+	var dst testBindRequest
+	if err := testBindServe(r, &dst, nil); err != nil {
+		t.Fatalf("%s expected null error, returned %v", t.Name(), err)
+	}
+	if dst.ID != 7 || dst.Name != "john" || dst.Age != 30 || len(dst.Tags) != 2 || dst.Limit == nil || *dst.Limit != 10 ||
+		dst.Timeout != time.Minute || !dst.Active || dst.Trace != "abc" {
+		t.Errorf("%s expected all fields bound, returned %+v", t.Name(), dst)
+	}
+}
+
+func TestBind_Form(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/1", strings.NewReader("name=jane&age=25"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var dst testBindRequest
+	if err := testBindServe(r, &dst, nil); err != nil || dst.Name != "jane" || dst.Age != 25 {
+		t.Errorf("%s expected form bound, returned %+v %v", t.Name(), dst, err)
+	}
+}
+
+func TestBind_XML(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/users/1", strings.NewReader("<user><name>jane</name><age>25</age></user>"))
+	r.Header.Set("Content-Type", "application/xml")
+	var dst testBindRequest
+	if err := testBindServe(r, &dst, nil); err != nil || dst.Name != "jane" || dst.Age != 25 {
+		t.Errorf("%s expected xml bound, returned %+v %v", t.Name(), dst, err)
+	}
+}
+
+func TestBind_FieldErrors(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/x?limit=-1", strings.NewReader(`{"name":"john","age":"old"}`))
+	r.Header.Set("Content-Type", "application/json")
+	var dst testBindRequest
+	err := testBindServe(r, &dst, nil)
+	var fe FieldErrors
+	if !errors.As(err, &fe) {
+		t.Fatalf("%s expected FieldErrors, returned %v", t.Name(), err)
+	}
+	fields := make(map[string]string)
+	for _, e := range fe {
+		fields[e.Field] = e.Source
+	}
+	if fields["age"] != "json" || fields["id"] != "path" || fields["limit"] != "query" {
+		t.Errorf("%s expected errors of age, id and limit, returned %v", t.Name(), fe)
+	}
+}
+
+func TestBind_UnknownFields(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/1", strings.NewReader(`{"name":"john","role":"admin"}`))
+	r.Header.Set("Content-Type", "application/json")
+	var dst testBindRequest
+	err := testBindServe(r, &dst, &BindOpts{DisallowUnknownFields: true})
+	fe, ok := err.(FieldErrors)
+	if !ok || len(fe) != 1 || fe[0].Field != "role" {
+		t.Errorf("%s expected unknown field role, returned %v", t.Name(), err)
+	}
+
+	r = httptest.NewRequest("POST", "/users/1", strings.NewReader("name=jane&role=admin"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err = testBindServe(r, &dst, &BindOpts{DisallowUnknownFields: true})
+	if fe, ok := err.(FieldErrors); !ok || len(fe) != 1 || fe[0].Field != "role" {
+		t.Errorf("%s expected unknown form field role, returned %v", t.Name(), err)
+	}
+}
+
+func TestBind_Invalid(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/1", strings.NewReader(`name`))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	var dst testBindRequest
+	if err := testBindServe(r, &dst, nil); err != ErrUnsupportedMediaType {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrUnsupportedMediaType, err)
+	}
+	r = httptest.NewRequest("POST", "/users/1", strings.NewReader(`{"name":`))
+	r.Header.Set("Content-Type", "application/json")
+	if err := testBindServe(r, &dst, nil); !errors.Is(err, ErrInvalidBody) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrInvalidBody, err)
+	}
+	if err := Bind(r, dst); err != ErrInvalidBindTarget {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrInvalidBindTarget, err)
+	}
+}
@@ -20,6 +20,10 @@ type Builder interface {
 	WithPanicHandler(func(w http.ResponseWriter, r *http.Request, rcv ...interface{})) *ServerBuilder
 	WithNotFoundHandler(http.HandlerFunc) *ServerBuilder
 	WithMiddleware(Middleware) *ServerBuilder
+	WithTrustedProxies(...string) *ServerBuilder
+	WithRequestID(*RequestIDOpts) *ServerBuilder
+	WithProblemDetails() *ServerBuilder
+	WithWebSocket(*WSOpts) *ServerBuilder
 
 	AddHandler(methodName string, path string, handler http.HandlerFunc, middlewares ...Middleware) *ServerBuilder
 	AddFilesServer(filePath string, rootPath string, middlewares ...Middleware) *ServerBuilder
@@ -70,7 +74,7 @@ func (sb *ServerBuilder) WithLogger(wr ...io.Writer) *ServerBuilder {
 	}
 	go write(buff, w)
 	sb.srv.logger = log.New(buff, "", 0)
-	sb.srv.middlewares = append(sb.srv.middlewares, sb.srv.log)
+	sb.srv.Use(Named(LoggerMiddleware, sb.srv.log))
 	return sb
 }
 
@@ -91,7 +95,27 @@ func (sb *ServerBuilder) WithNotFoundHandler(notFoundHandlerFunc http.HandlerFun
 }
 
 func (sb *ServerBuilder) WithMiddleware(middleware Middleware) *ServerBuilder {
-	sb.srv.middlewares = append(sb.srv.middlewares, middleware)
+	sb.srv.Use(middleware)
+	return sb
+}
+
+func (sb *ServerBuilder) WithTrustedProxies(cidrs ...string) *ServerBuilder {
+	sb.srv.trustedProxies = append(sb.srv.trustedProxies, parseTrustedProxies(cidrs)...)
+	return sb
+}
+
+func (sb *ServerBuilder) WithRequestID(opts *RequestIDOpts) *ServerBuilder {
+	sb.srv.requestID = newRequestIDConfig(opts)
+	return sb
+}
+
+func (sb *ServerBuilder) WithProblemDetails() *ServerBuilder {
+	sb.srv.enableProblemDetails()
+	return sb
+}
+
+func (sb *ServerBuilder) WithWebSocket(opts *WSOpts) *ServerBuilder {
+	sb.srv.ws.opts = opts
 	return sb
 }
 
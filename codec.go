@@ -0,0 +1,255 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCodecDepth maximum nesting of decoded values, protecting against stack exhaustion by malicious input.
+const maxCodecDepth = 1000
+
+var ErrCodecDepth = errors.New("httpserver: value nested too deeply")
+
+// Codec encode and decode values of a wire format.
+// Codec may also implement DecodeStrict(r io.Reader, v interface{}) error, used by BindOpts.DisallowUnknownFields.
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+type strictDecoder interface {
+	DecodeStrict(r io.Reader, v interface{}) error
+}
+
+// codecRegistry codecs keyed by media type.
+type codecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+var codecs = &codecRegistry{
+	codecs: map[string]Codec{
+		"application/json":      JSONCodec{},
+		"application/xml":       XMLCodec{},
+		"text/xml":              XMLCodec{},
+		"application/yaml":      YAMLCodec{},
+		"application/x-yaml":    YAMLCodec{},
+		"text/yaml":             YAMLCodec{},
+		"text/csv":              CSVCodec{},
+		"application/msgpack":   MsgPackCodec{},
+		"application/x-msgpack": MsgPackCodec{},
+		"application/cbor":      CBORCodec{},
+	},
+}
+
+// structuredSuffixes media type of structured syntax suffixes, e.g. "application/problem+json" is looked up as json.
+var structuredSuffixes = map[string]string{
+	"json": "application/json",
+	"xml":  "application/xml",
+	"yaml": "application/yaml",
+	"cbor": "application/cbor",
+}
+
+// RegisterCodec add codec of media type, or replace existing one, e.g. a faster JSON implementation for "application/json".
+// It is used by Bind for request bodies, and by ResponseCodec and Respond for responses.
+func RegisterCodec(mediaType string, c Codec) {
+	mediaType = strings.ToLower(mediaType)
+	codecs.mu.Lock()
+	codecs.codecs[mediaType] = c
+	codecs.mu.Unlock()
+	if !defaultNegotiator.has(mediaType) {
+		RegisterEncoder(mediaType, codecEncoder(mediaType))
+	}
+}
+
+// LookupCodec return codec of media type, parameters are ignored.
+// Media types with structured suffix without own codec use codec of the suffix, e.g. "application/vnd.api+json".
+func LookupCodec(mediaType string) (Codec, bool) {
+	if mt, _, err := mime.ParseMediaType(mediaType); err == nil {
+		mediaType = mt
+	}
+	mediaType = strings.ToLower(mediaType)
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+	if c, ok := codecs.codecs[mediaType]; ok {
+		return c, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		if base, ok := structuredSuffixes[mediaType[i+1:]]; ok {
+			c, ok := codecs.codecs[base]
+			return c, ok
+		}
+	}
+	return nil, false
+}
+
+// ResponseCodec response by encoding body with codec registered for media type, which is sent as Content-Type.
+// Call at the end line of your handler.
+func ResponseCodec(w http.ResponseWriter, statusCode int, mediaType string, body interface{}) error {
+	c, ok := LookupCodec(mediaType)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+	}
+	w.Header().Set("Content-Type", mediaType)
+	responseHeader(w, statusCode)
+	return c.Encode(w, body)
+}
+
+func codecEncoder(mediaType string) Encoder {
+	return func(w http.ResponseWriter, statusCode int, v interface{}) error {
+		return ResponseCodec(w, statusCode, mediaType, v)
+	}
+}
+
+// JSONCodec codec of encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSONCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// DecodeStrict decode rejecting fields not declared in v.
+func (JSONCodec) DecodeStrict(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// XMLCodec codec of encoding/xml.
+type XMLCodec struct{}
+
+func (XMLCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (XMLCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// mapItem entry of orderedMap.
+type mapItem struct {
+	Key   string
+	Value interface{}
+}
+
+// orderedMap object keeping key order, generic form of structs and maps shared by codecs built on json tags.
+type orderedMap []mapItem
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, item := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// toGeneric convert v, honoring its json tags, into nil, bool, json.Number, string, []interface{} or orderedMap.
+func toGeneric(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeGenericJSON(dec)
+}
+
+func decodeGenericJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	switch d {
+	case '{':
+		m := orderedMap{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeGenericJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, mapItem{k.(string), v})
+		}
+		_, err = dec.Token()
+		return m, err
+	case '[':
+		s := []interface{}{}
+		for dec.More() {
+			v, err := decodeGenericJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		_, err = dec.Token()
+		return s, err
+	}
+	return nil, fmt.Errorf("httpserver: unexpected json delimiter %v", d)
+}
+
+// fromGeneric store generic value decoded by a codec into v, honoring its json tags.
+func fromGeneric(g interface{}, v interface{}) error {
+	b, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// genericNumber split json.Number into integer or float.
+func genericNumber(n json.Number) (i int64, u uint64, f float64, kind byte) {
+	if i, err := n.Int64(); err == nil {
+		return i, 0, 0, 'i'
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return 0, u, 0, 'u'
+	}
+	f, _ = n.Float64()
+	return 0, 0, f, 'f'
+}
+
+// genericKey stringify map key decoded from binary formats allowing non string keys.
+func genericKey(k interface{}) string {
+	switch t := k.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	}
+	return fmt.Sprint(k)
+}
@@ -0,0 +1,324 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+var ErrCBORSyntax = errors.New("httpserver: invalid cbor")
+
+// CBOR major types, see RFC 8949.
+const (
+	cborUint byte = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborIndefinite additional information of indefinite length items, ended by cborBreak.
+const (
+	cborIndefinite = 31
+	cborBreak      = 0xff
+)
+
+// CBORCodec codec of CBOR, see RFC 8949, values are converted through their json tags.
+// Tags are ignored and their content decoded as is, undefined is decoded as null.
+type CBORCodec struct{}
+
+func (CBORCodec) Encode(w io.Writer, v interface{}) error {
+	g, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeCBOR(&buf, g)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (CBORCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return io.EOF
+	}
+	d := &binaryDecoder{b: b, syntaxErr: ErrCBORSyntax}
+	g, err := d.cbor(0)
+	if err != nil {
+		return err
+	}
+	if g == cborBreakMarker {
+		return fmt.Errorf("%w: unexpected break", ErrCBORSyntax)
+	}
+	if d.i != len(d.b) {
+		return fmt.Errorf("%w: trailing data", ErrCBORSyntax)
+	}
+	return fromGeneric(g, v)
+}
+
+func writeCBOR(buf *bytes.Buffer, v interface{}) {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple | 22)
+	case bool:
+		if t {
+			buf.WriteByte(cborSimple | 21)
+		} else {
+			buf.WriteByte(cborSimple | 20)
+		}
+	case json.Number:
+		i, u, f, kind := genericNumber(t)
+		switch {
+		case kind == 'u':
+			writeCBORHead(buf, cborUint, u)
+		case kind == 'f':
+			buf.WriteByte(cborSimple | 27)
+			writeUint(buf, math.Float64bits(f), 8)
+		case i >= 0:
+			writeCBORHead(buf, cborUint, uint64(i))
+		default:
+			writeCBORHead(buf, cborNegInt, uint64(-(i + 1)))
+		}
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(t)))
+		buf.WriteString(t)
+	case []interface{}:
+		writeCBORHead(buf, cborArray, uint64(len(t)))
+		for _, item := range t {
+			writeCBOR(buf, item)
+		}
+	case orderedMap:
+		writeCBORHead(buf, cborMap, uint64(len(t)))
+		for _, item := range t {
+			writeCBOR(buf, item.Key)
+			writeCBOR(buf, item.Value)
+		}
+	}
+}
+
+// writeCBORHead write major type with argument in the shortest form.
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		writeUint(buf, n, 2)
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		writeUint(buf, n, 4)
+	default:
+		buf.WriteByte(major | 27)
+		writeUint(buf, n, 8)
+	}
+}
+
+// cborBreakMarker returned by cbor when break ends an indefinite length item.
+var cborBreakMarker = &struct{}{}
+
+// cborArg read argument of additional information, indefinite is reported by ok false.
+func (d *binaryDecoder) cborArg(info byte) (n uint64, ok bool, err error) {
+	switch {
+	case info < 24:
+		return uint64(info), true, nil
+	case info <= 27:
+		n, err = d.uint(1 << (info - 24))
+		return n, true, err
+	case info == cborIndefinite:
+		return 0, false, nil
+	}
+	return 0, false, fmt.Errorf("%w: reserved additional information %d", ErrCBORSyntax, info)
+}
+
+func (d *binaryDecoder) cbor(depth int) (interface{}, error) {
+	if depth > maxCodecDepth {
+		return nil, ErrCodecDepth
+	}
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	major, info := b[0]&0xe0, b[0]&0x1f
+	if b[0] == cborBreak {
+		return cborBreakMarker, nil
+	}
+	if major == cborSimple {
+		return d.cborSimple(info)
+	}
+	n, definite, err := d.cborArg(info)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if definite {
+			return n, nil
+		}
+	case cborNegInt:
+		if definite {
+			if n > math.MaxInt64 {
+				return -float64(n) - 1, nil
+			}
+			return -int64(n) - 1, nil
+		}
+	case cborBytes, cborText:
+		var s []byte
+		if definite {
+			s, err = d.next(n)
+		} else {
+			s, err = d.cborChunks(major)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(s), nil
+		}
+		return s, nil
+	case cborArray:
+		return d.cborArray(n, definite, depth)
+	case cborMap:
+		return d.cborMap(n, definite, depth)
+	case cborTag:
+		if definite {
+			return d.cbor(depth + 1)
+		}
+	}
+	return nil, fmt.Errorf("%w: invalid indefinite length of major type %d", ErrCBORSyntax, major>>5)
+}
+
+func (d *binaryDecoder) cborSimple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		u, err := d.uint(2)
+		return halfToFloat(uint16(u)), err
+	case 26:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 27:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	}
+	return nil, fmt.Errorf("%w: unsupported simple value %d", ErrCBORSyntax, info)
+}
+
+// cborChunks concatenate definite length chunks of an indefinite length byte or text string.
+func (d *binaryDecoder) cborChunks(major byte) ([]byte, error) {
+	var s []byte
+	for {
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == cborBreak {
+			return s, nil
+		}
+		if b[0]&0xe0 != major {
+			return nil, fmt.Errorf("%w: invalid chunk of indefinite length string", ErrCBORSyntax)
+		}
+		n, definite, err := d.cborArg(b[0] & 0x1f)
+		if err != nil {
+			return nil, err
+		}
+		if !definite {
+			return nil, fmt.Errorf("%w: nested indefinite length string", ErrCBORSyntax)
+		}
+		chunk, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+func (d *binaryDecoder) cborArray(n uint64, definite bool, depth int) (interface{}, error) {
+	if err := d.checkLen(n); err != nil {
+		return nil, err
+	}
+	s := make([]interface{}, 0, n)
+	for i := uint64(0); !definite || i < n; i++ {
+		v, err := d.cbor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if v == cborBreakMarker {
+			if definite {
+				return nil, fmt.Errorf("%w: unexpected break", ErrCBORSyntax)
+			}
+			break
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+func (d *binaryDecoder) cborMap(n uint64, definite bool, depth int) (interface{}, error) {
+	if err := d.checkLen(n); err != nil {
+		return nil, err
+	}
+	m := make(orderedMap, 0, n)
+	for i := uint64(0); !definite || i < n; i++ {
+		k, err := d.cbor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if k == cborBreakMarker {
+			if definite {
+				return nil, fmt.Errorf("%w: unexpected break", ErrCBORSyntax)
+			}
+			break
+		}
+		v, err := d.cbor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if v == cborBreakMarker {
+			return nil, fmt.Errorf("%w: unexpected break", ErrCBORSyntax)
+		}
+		m = append(m, mapItem{genericKey(k), v})
+	}
+	return m, nil
+}
+
+// halfToFloat convert IEEE 754 half precision float.
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
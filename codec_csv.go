@@ -0,0 +1,213 @@
+package httpserver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// CSVCodec codec of comma separated values with header row, see RFC 4180.
+// Encode take [][]string, or struct, map or slice of them whose columns are named by json tags, nested values are
+// written as json. Decode take pointer to [][]string, or slice of struct or map, struct or map taking the first row.
+type CSVCodec struct{}
+
+var stringRowsType = reflect.TypeOf([][]string(nil))
+
+func (CSVCodec) Encode(w io.Writer, v interface{}) error {
+	cw := csv.NewWriter(w)
+	if rows, ok := v.([][]string); ok {
+		return cw.WriteAll(rows)
+	}
+	g, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var rows []orderedMap
+	switch t := g.(type) {
+	case orderedMap:
+		rows = append(rows, t)
+	case []interface{}:
+		for _, item := range t {
+			m, ok := item.(orderedMap)
+			if !ok {
+				return fmt.Errorf("httpserver: csv rows must be structs or maps, got %T", item)
+			}
+			rows = append(rows, m)
+		}
+	default:
+		return fmt.Errorf("httpserver: csv value must be a struct, map or slice, got %T", v)
+	}
+
+	var header []string
+	columns := make(map[string]int)
+	for _, row := range rows {
+		for _, item := range row {
+			if _, ok := columns[item.Key]; !ok {
+				columns[item.Key] = len(header)
+				header = append(header, item.Key)
+			}
+		}
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i := range record {
+			record[i] = ""
+		}
+		for _, item := range row {
+			record[columns[item.Key]], err = csvCell(item.Value)
+			if err != nil {
+				return err
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvCell(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	case bool:
+		if t {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Decode convert cells into field types like query values, see Bind. Return FieldErrors for invalid cells.
+func (CSVCodec) Decode(r io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("httpserver: csv decode target must be a non-nil pointer, got %T", v)
+	}
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	target := rv.Elem()
+	if target.Type() == stringRowsType {
+		target.Set(reflect.ValueOf(records))
+		return nil
+	}
+	if len(records) == 0 {
+		return io.EOF
+	}
+	header, rows := records[0], records[1:]
+
+	var errs FieldErrors
+	switch target.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(target.Type(), len(rows), len(rows))
+		for i, row := range rows {
+			errs = append(errs, csvRow(s.Index(i), header, row, fmt.Sprintf("[%d].", i))...)
+		}
+		target.Set(s)
+	case reflect.Struct, reflect.Map, reflect.Ptr:
+		if len(rows) > 0 {
+			errs = csvRow(target, header, rows[0], "")
+		}
+	default:
+		return fmt.Errorf("httpserver: unsupported csv decode target %T", v)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// csvRow set struct fields named by json tags, or map entries, from cells of row.
+func csvRow(v reflect.Value, header []string, row []string, prefix string) FieldErrors {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	var errs FieldErrors
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return FieldErrors{{Field: prefix, Source: "csv", Code: "type", Message: "map key must be a string"}}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for i, name := range header {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if ev.Kind() == reflect.Interface {
+				ev.Set(reflect.ValueOf(row[i]))
+			} else if err := setValue(ev, row[i]); err != nil {
+				errs = append(errs, FieldError{Field: prefix + name, Source: "csv", Code: "type", Message: err.Error()})
+				continue
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(v.Type().Key()), ev)
+		}
+	case reflect.Struct:
+		fields := csvFields(v.Type())
+		for i, name := range header {
+			index, ok := fields[name]
+			if !ok {
+				index, ok = fields[strings.ToLower(name)]
+			}
+			if !ok || row[i] == "" {
+				continue
+			}
+			if err := setValue(v.FieldByIndex(index), row[i]); err != nil {
+				errs = append(errs, FieldError{Field: prefix + name, Source: "csv", Code: "type", Message: err.Error()})
+			}
+		}
+	default:
+		return FieldErrors{{Field: prefix, Source: "csv", Code: "type", Message: "row must be a struct or map"}}
+	}
+	return errs
+}
+
+// csvFields index of exported fields by json name, and by lower case name for case insensitive match like encoding/json.
+func csvFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			idx := append(append([]int(nil), index...), i)
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("json") == "" {
+				walk(sf.Type, idx)
+				continue
+			}
+			if sf.PkgPath != "" || sf.Tag.Get("json") == "-" {
+				continue
+			}
+			name := tagName(sf, "json")
+			if name == "" {
+				name = sf.Name
+			}
+			if _, ok := fields[name]; !ok {
+				fields[name] = idx
+			}
+			if lower := strings.ToLower(name); lower != name {
+				if _, ok := fields[lower]; !ok {
+					fields[lower] = idx
+				}
+			}
+		}
+	}
+	walk(t, nil)
+	return fields
+}
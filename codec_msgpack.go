@@ -0,0 +1,294 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+var ErrMsgPackSyntax = errors.New("httpserver: invalid msgpack")
+
+// msgPackLenSizes size in bytes of length of bin, str, array and map by type code.
+var msgPackLenSizes = map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4, 0xdc: 2, 0xdd: 4, 0xde: 2, 0xdf: 4}
+
+// MsgPackCodec codec of MessagePack, values are converted through their json tags.
+// Binary values are decoded into []byte fields, extension types are not supported.
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) Encode(w io.Writer, v interface{}) error {
+	g, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeMsgPack(&buf, g)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (MsgPackCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return io.EOF
+	}
+	d := &binaryDecoder{b: b, syntaxErr: ErrMsgPackSyntax}
+	g, err := d.msgPack(0)
+	if err != nil {
+		return err
+	}
+	if d.i != len(d.b) {
+		return fmt.Errorf("%w: trailing data", ErrMsgPackSyntax)
+	}
+	return fromGeneric(g, v)
+}
+
+func writeMsgPack(buf *bytes.Buffer, v interface{}) {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if t {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		i, u, f, kind := genericNumber(t)
+		switch {
+		case kind == 'u':
+			buf.WriteByte(0xcf)
+			writeUint(buf, u, 8)
+		case kind == 'f':
+			buf.WriteByte(0xcb)
+			writeUint(buf, math.Float64bits(f), 8)
+		case i >= 0 && i < 128:
+			buf.WriteByte(byte(i))
+		case i >= 0:
+			writeMsgPackUint(buf, uint64(i))
+		case i >= -32:
+			buf.WriteByte(byte(int8(i)))
+		case i >= math.MinInt8:
+			buf.WriteByte(0xd0)
+			buf.WriteByte(byte(int8(i)))
+		case i >= math.MinInt16:
+			buf.WriteByte(0xd1)
+			writeUint(buf, uint64(uint16(int16(i))), 2)
+		case i >= math.MinInt32:
+			buf.WriteByte(0xd2)
+			writeUint(buf, uint64(uint32(int32(i))), 4)
+		default:
+			buf.WriteByte(0xd3)
+			writeUint(buf, uint64(i), 8)
+		}
+	case string:
+		n := len(t)
+		switch {
+		case n < 32:
+			buf.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			buf.WriteByte(0xd9)
+			buf.WriteByte(byte(n))
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			writeUint(buf, uint64(n), 2)
+		default:
+			buf.WriteByte(0xdb)
+			writeUint(buf, uint64(n), 4)
+		}
+		buf.WriteString(t)
+	case []interface{}:
+		writeMsgPackLen(buf, len(t), 0x90, 0xdc)
+		for _, item := range t {
+			writeMsgPack(buf, item)
+		}
+	case orderedMap:
+		writeMsgPackLen(buf, len(t), 0x80, 0xde)
+		for _, item := range t {
+			writeMsgPack(buf, item.Key)
+			writeMsgPack(buf, item.Value)
+		}
+	}
+}
+
+func writeMsgPackUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		writeUint(buf, u, 2)
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		writeUint(buf, u, 4)
+	default:
+		buf.WriteByte(0xcf)
+		writeUint(buf, u, 8)
+	}
+}
+
+// writeMsgPackLen write array or map header, fix is the fix type prefix and code16 the 16 bit type, followed by 32 bit.
+func writeMsgPackLen(buf *bytes.Buffer, n int, fix byte, code16 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		writeUint(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(code16 + 1)
+		writeUint(buf, uint64(n), 4)
+	}
+}
+
+// writeUint write big endian unsigned integer of size bytes.
+func writeUint(buf *bytes.Buffer, u uint64, size int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	buf.Write(b[8-size:])
+}
+
+// binaryDecoder reader of binary codec input with bounds checks.
+type binaryDecoder struct {
+	b         []byte
+	i         int
+	syntaxErr error
+}
+
+func (d *binaryDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.i) {
+		return nil, fmt.Errorf("%w: unexpected end of data", d.syntaxErr)
+	}
+	b := d.b[d.i : d.i+int(n)]
+	d.i += int(n)
+	return b, nil
+}
+
+func (d *binaryDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// checkLen reject collection lengths that can't fit into remaining data, each item taking at least one byte.
+func (d *binaryDecoder) checkLen(n uint64) error {
+	if n > uint64(len(d.b)-d.i) {
+		return fmt.Errorf("%w: length %d exceeds data", d.syntaxErr, n)
+	}
+	return nil
+}
+
+func (d *binaryDecoder) msgPack(depth int) (interface{}, error) {
+	if depth > maxCodecDepth {
+		return nil, ErrCodecDepth
+	}
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.msgPackMap(uint64(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.msgPackArray(uint64(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.str(uint64(c & 0x1f))
+	}
+
+	if size, ok := msgPackLenSizes[c]; ok {
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case c <= 0xc6:
+			return d.next(n)
+		case c <= 0xdb:
+			return d.str(n)
+		case c <= 0xdd:
+			return d.msgPackArray(n, depth)
+		}
+		return d.msgPackMap(n, depth)
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.uint(size)
+		shift := uint(64 - 8*size)
+		return int64(u<<shift) >> shift, err
+	}
+	return nil, fmt.Errorf("%w: unsupported type 0x%02x", ErrMsgPackSyntax, c)
+}
+
+func (d *binaryDecoder) str(n uint64) (interface{}, error) {
+	b, err := d.next(n)
+	return string(b), err
+}
+
+func (d *binaryDecoder) msgPackArray(n uint64, depth int) (interface{}, error) {
+	if err := d.checkLen(n); err != nil {
+		return nil, err
+	}
+	s := make([]interface{}, 0, n)
+	for ; n > 0; n-- {
+		v, err := d.msgPack(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+func (d *binaryDecoder) msgPackMap(n uint64, depth int) (interface{}, error) {
+	if err := d.checkLen(n); err != nil {
+		return nil, err
+	}
+	m := make(orderedMap, 0, n)
+	for ; n > 0; n-- {
+		k, err := d.msgPack(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.msgPack(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, mapItem{genericKey(k), v})
+	}
+	return m, nil
+}
@@ -0,0 +1,216 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testCodecItem struct {
+	Name  string            `json:"name"`
+	Price float64           `json:"price"`
+	Count int               `json:"count"`
+	Tags  []string          `json:"tags"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+	Data  []byte            `json:"data,omitempty"`
+	Next  *testCodecItem    `json:"next,omitempty"`
+}
+
+func TestLookupCodec(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		codec     Codec
+		ok        bool
+	}{
+		{"application/json", JSONCodec{}, true},
+		{"application/json; charset=utf-8", JSONCodec{}, true},
+		{"application/problem+json", JSONCodec{}, true},
+		{"application/vnd.api+JSON", JSONCodec{}, true},
+		{"application/atom+xml", XMLCodec{}, true},
+		{"text/yaml", YAMLCodec{}, true},
+		{"application/cbor", CBORCodec{}, true},
+		{"application/x-msgpack", MsgPackCodec{}, true},
+		{"application/octet-stream", nil, false},
+		{"application/vnd.foo+zip", nil, false},
+	}
+	for _, tt := range tests {
+		c, ok := LookupCodec(tt.mediaType)
+		if ok != tt.ok || c != tt.codec {
+			t.Errorf("%s expected %T %v for %s, returned %T %v", t.Name(), tt.codec, tt.ok, tt.mediaType, c, ok)
+		}
+	}
+}
+
+type testUpperJSONCodec struct{ JSONCodec }
+
+func (c testUpperJSONCodec) Encode(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	if err := c.JSONCodec.Encode(&buf, v); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.ToUpper(buf.Bytes()))
+	return err
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("application/json", testUpperJSONCodec{})
+	defer RegisterCodec("application/json", JSONCodec{})
+
+	w := httptest.NewRecorder()
+	ResponseJSON(w, 200, map[string]string{"name": "john"})
+	if strings.TrimSpace(w.Body.String()) != `{"NAME":"JOHN"}` {
+		t.Errorf("%s expected registered json codec, returned %s", t.Name(), w.Body.String())
+	}
+
+	RegisterCodec("application/x-test", testUpperJSONCodec{})
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/x-test")
+	if err := Respond(w, r, 200, map[string]string{"name": "john"}); err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	if w.Header().Get("Content-Type") != "application/x-test" || !strings.Contains(w.Body.String(), "JOHN") {
+		t.Errorf("%s expected application/x-test response, returned %s %s", t.Name(), w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	in := testCodecItem{
+		Name:  "tea: green #1",
+		Price: 2.5,
+		Count: -300,
+		Tags:  []string{"hot", "", "true"},
+		Attrs: map[string]string{"origin": "japan"},
+		Data:  []byte{0, 1, 2},
+		Next:  &testCodecItem{Name: "cup", Count: 70000, Tags: []string{}},
+	}
+	for _, mediaType := range []string{"application/json", "application/yaml", "application/msgpack", "application/cbor"} {
+		c, _ := LookupCodec(mediaType)
+		var buf bytes.Buffer
+		if err := c.Encode(&buf, in); err != nil {
+			t.Fatalf("%s expected no encode error for %s, returned %v", t.Name(), mediaType, err)
+		}
+		var out testCodecItem
+		if err := c.Decode(&buf, &out); err != nil {
+			t.Fatalf("%s expected no decode error for %s, returned %v", t.Name(), mediaType, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s expected %+v for %s, returned %+v", t.Name(), in, mediaType, out)
+		}
+	}
+}
+
+func TestYAMLCodec_Decode(t *testing.T) {
+	doc := `# item
+name: "tea"   # quoted
+price: 2.5
+tags:
+- hot
+- 'it''s'
+attrs: {origin: japan, grade: "A"}
+next:
+  name: |
+    cup
+    # not a comment
+  tags: [a, b]
+`
+	var out testCodecItem
+	if err := (YAMLCodec{}).Decode(strings.NewReader(doc), &out); err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	expected := testCodecItem{
+		Name:  "tea",
+		Price: 2.5,
+		Tags:  []string{"hot", "it's"},
+		Attrs: map[string]string{"origin": "japan", "grade": "A"},
+		Next:  &testCodecItem{Name: "cup\n# not a comment\n", Tags: []string{"a", "b"}},
+	}
+	if !reflect.DeepEqual(expected, out) {
+		t.Errorf("%s expected %+v, returned %+v", t.Name(), expected, out)
+	}
+
+	if err := (YAMLCodec{}).Decode(strings.NewReader("name: [a"), &out); err == nil {
+		t.Errorf("%s expected syntax error, returned nil", t.Name())
+	}
+}
+
+func TestCSVCodec(t *testing.T) {
+	in := []testCodecItem{{Name: "tea, green", Price: 2.5, Count: 1}, {Name: "cup", Count: 2, Tags: []string{"a"}}}
+	var buf bytes.Buffer
+	if err := (CSVCodec{}).Encode(&buf, in); err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	expected := "name,price,count,tags\n\"tea, green\",2.5,1,\ncup,0,2,\"[\"\"a\"\"]\"\n"
+	if buf.String() != expected {
+		t.Errorf("%s expected %q, returned %q", t.Name(), expected, buf.String())
+	}
+
+	var out []struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+		Count int     `json:"count"`
+	}
+	if err := (CSVCodec{}).Decode(strings.NewReader("NAME,price,count\ntea,2.5,1\ncup,x,2\n"), &out); err == nil {
+		t.Errorf("%s expected field error, returned nil", t.Name())
+	} else if fe, ok := err.(FieldErrors); !ok || fe[0].Field != "[1].price" {
+		t.Errorf("%s expected field error of [1].price, returned %v", t.Name(), err)
+	}
+	if len(out) != 2 || out[0].Name != "tea" || out[0].Price != 2.5 || out[1].Count != 2 {
+		t.Errorf("%s expected decoded rows, returned %+v", t.Name(), out)
+	}
+}
+
+func TestBind_Codec(t *testing.T) {
+	var b bytes.Buffer
+	(CBORCodec{}).Encode(&b, map[string]interface{}{"name": "jane", "age": 30})
+	r := httptest.NewRequest("POST", "/users/1", &b)
+	r.Header.Set("Content-Type", "application/cbor")
+	var dst testBindRequest
+	if err := testBindServe(r, &dst, nil); err != nil || dst.Name != "jane" {
+		t.Errorf("%s expected name jane, returned %+v %v", t.Name(), dst, err)
+	}
+
+	r = httptest.NewRequest("POST", "/users/1", strings.NewReader("name: jane\nage: old\n"))
+	r.Header.Set("Content-Type", "application/yaml")
+	err := testBindServe(r, &dst, nil)
+	if fe, ok := err.(FieldErrors); !ok || fe[0].Field != "age" {
+		t.Errorf("%s expected field error of age, returned %v", t.Name(), err)
+	}
+}
+
+func TestMsgPackCodec_Decode(t *testing.T) {
+	// {"a": [1, -1, 1.5, nil, true], "b": bin "xy"}
+	in := []byte{0x82, 0xa1, 'a', 0x95, 0x01, 0xff, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xc0, 0xc3, 0xa1, 'b', 0xc4, 0x02, 'x', 'y'}
+	var out map[string]interface{}
+	if err := (MsgPackCodec{}).Decode(bytes.NewReader(in), &out); err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	b, _ := json.Marshal(out)
+	if string(b) != `{"a":[1,-1,1.5,null,true],"b":"eHk="}` {
+		t.Errorf("%s expected decoded map, returned %s", t.Name(), b)
+	}
+	if err := (MsgPackCodec{}).Decode(bytes.NewReader([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}), &out); err == nil {
+		t.Errorf("%s expected error of oversized array, returned nil", t.Name())
+	}
+}
+
+func TestCBORCodec_Decode(t *testing.T) {
+	// indefinite map {"a": indefinite text "x"+"y", "b": half float 1.5, "c": tag 1 (1000)}
+	in := []byte{0xbf, 0x61, 'a', 0x7f, 0x61, 'x', 0x61, 'y', 0xff, 0x61, 'b', 0xf9, 0x3e, 0x00, 0x61, 'c', 0xc1, 0x19, 0x03, 0xe8, 0xff}
+	var out map[string]interface{}
+	if err := (CBORCodec{}).Decode(bytes.NewReader(in), &out); err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	b, _ := json.Marshal(out)
+	if string(b) != `{"a":"xy","b":1.5,"c":1000}` {
+		t.Errorf("%s expected decoded map, returned %s", t.Name(), b)
+	}
+	deep := append(bytes.Repeat([]byte{0x81}, maxCodecDepth+2), 0x01)
+	if err := (CBORCodec{}).Decode(bytes.NewReader(deep), &out); err != ErrCodecDepth {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrCodecDepth, err)
+	}
+}
@@ -0,0 +1,631 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrYAMLSyntax = errors.New("httpserver: invalid yaml")
+
+// YAMLCodec codec of the commonly used subset of YAML 1.2: block and flow mappings and sequences, plain and quoted
+// scalars, literal and folded block scalars, and comments. Anchors, aliases, tags and multiple documents are not supported.
+// Values are converted through their json tags.
+type YAMLCodec struct{}
+
+func (YAMLCodec) Encode(w io.Writer, v interface{}) error {
+	g, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	switch t := g.(type) {
+	case orderedMap:
+		if len(t) == 0 {
+			buf.WriteString("{}\n")
+		} else {
+			writeYAMLMap(&buf, t, 0, false)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			buf.WriteString("[]\n")
+		} else {
+			writeYAMLSeq(&buf, t, 0)
+		}
+	default:
+		buf.WriteString(yamlScalar(g))
+		buf.WriteByte('\n')
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (YAMLCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	p := &yamlParser{lines: yamlLines(string(b))}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return io.EOF
+	}
+	g, err := p.parseBlock(0)
+	if err != nil {
+		return err
+	}
+	if p.skipBlank(); p.pos < len(p.lines) {
+		return fmt.Errorf("%w: line %d: unexpected content", ErrYAMLSyntax, p.lines[p.pos].num)
+	}
+	return fromGeneric(g, v)
+}
+
+// writeYAMLMap write items at indent column, the first one without indent if it follows "- " of a sequence.
+func writeYAMLMap(buf *bytes.Buffer, m orderedMap, indent int, inline bool) {
+	for i, item := range m {
+		if i > 0 || !inline {
+			buf.WriteString(strings.Repeat(" ", indent))
+		}
+		buf.WriteString(yamlString(item.Key))
+		buf.WriteByte(':')
+		writeYAMLValue(buf, item.Value, indent+2)
+	}
+}
+
+func writeYAMLSeq(buf *bytes.Buffer, s []interface{}, indent int) {
+	for _, v := range s {
+		buf.WriteString(strings.Repeat(" ", indent))
+		buf.WriteByte('-')
+		if m, ok := v.(orderedMap); ok && len(m) > 0 {
+			buf.WriteByte(' ')
+			writeYAMLMap(buf, m, indent+2, true)
+			continue
+		}
+		writeYAMLValue(buf, v, indent+2)
+	}
+}
+
+// writeYAMLValue write value following "key:" or "-".
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	switch t := v.(type) {
+	case orderedMap:
+		if len(t) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteByte('\n')
+		writeYAMLMap(buf, t, indent, false)
+	case []interface{}:
+		if len(t) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteByte('\n')
+		writeYAMLSeq(buf, t, indent)
+	default:
+		buf.WriteByte(' ')
+		buf.WriteString(yamlScalar(v))
+		buf.WriteByte('\n')
+	}
+}
+
+func yamlScalar(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case string:
+		return yamlString(t)
+	}
+	return yamlString(fmt.Sprint(v))
+}
+
+// yamlString quote s if it would not be read back as the same plain string.
+func yamlString(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, ":#\n\r\t\"'\\{}[],&*!|>%@`") ||
+		strings.HasPrefix(s, "- ") || s == "-" || s == "?" || strings.HasPrefix(s, "? ") {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	if _, ok := parseYAMLPlain(s).(string); !ok {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	return s
+}
+
+type yamlLine struct {
+	num     int
+	indent  int
+	content string
+
+	// raw content with comments, kept for block scalars.
+	raw string
+}
+
+// yamlLines split document into lines with comments removed, skipping directives and document markers.
+func yamlLines(doc string) []yamlLine {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n") {
+		if raw == "---" || raw == "..." || strings.HasPrefix(raw, "%") {
+			continue
+		}
+		trimmed := strings.TrimLeft(raw, " ")
+		lines = append(lines, yamlLine{
+			num:     i + 1,
+			indent:  len(raw) - len(trimmed),
+			content: strings.TrimRight(stripYAMLComment(trimmed), " \t"),
+			raw:     strings.TrimRight(trimmed, " \t"),
+		})
+	}
+	return lines
+}
+
+// stripYAMLComment remove "#" comment not inside quotes, a comment starts a line or follows whitespace.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(s[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// skipBlank move past empty lines.
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].content == "" {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) parseBlock(depth int) (interface{}, error) {
+	if depth > maxCodecDepth {
+		return nil, ErrCodecDepth
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	switch {
+	case isYAMLSeqItem(l.content):
+		return p.parseSeq(l.indent, depth)
+	case yamlKeyEnd(l.content) >= 0:
+		return p.parseMap(l.indent, depth)
+	}
+	p.pos++
+	return parseYAMLValue(l.content, l.num)
+}
+
+func isYAMLSeqItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+func (p *yamlParser) parseSeq(indent int, depth int) (interface{}, error) {
+	s := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return s, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && !isYAMLSeqItem(l.content)) {
+			return s, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("%w: line %d: bad indentation of a sequence entry", ErrYAMLSyntax, l.num)
+		}
+		rest := strings.TrimLeft(l.content[1:], " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.parseNested(indent, depth)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+		if isYAMLSeqItem(rest) || yamlKeyEnd(rest) >= 0 {
+			// compact nested collection, e.g. "- key: value", continues at the column of its content.
+			col := l.indent + len(l.content) - len(rest)
+			p.lines[p.pos] = yamlLine{num: l.num, indent: col, content: rest, raw: rest}
+			v, err := p.parseBlock(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+		p.pos++
+		v, err := p.parseInline(rest, l, depth)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+}
+
+func (p *yamlParser) parseMap(indent int, depth int) (interface{}, error) {
+	m := orderedMap{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return m, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && isYAMLSeqItem(l.content)) {
+			return m, nil
+		}
+		end := yamlKeyEnd(l.content)
+		if l.indent > indent || end < 0 {
+			return nil, fmt.Errorf("%w: line %d: expected a mapping key", ErrYAMLSyntax, l.num)
+		}
+		key, err := parseYAMLKey(l.content[:end], l.num)
+		if err != nil {
+			return nil, err
+		}
+		rest := strings.TrimLeft(l.content[end+1:], " ")
+		p.pos++
+		var v interface{}
+		if rest == "" {
+			v, err = p.parseNested(indent, depth)
+			// sequences are allowed at the same indent as their key.
+			if err == nil && v == nil && p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].content) {
+				v, err = p.parseSeq(indent, depth+1)
+			}
+		} else {
+			v, err = p.parseInline(rest, l, depth)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, mapItem{key, v})
+	}
+}
+
+// parseNested parse block more indented than parent, or null if there is none.
+func (p *yamlParser) parseNested(parent int, depth int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent {
+		return nil, nil
+	}
+	return p.parseBlock(depth + 1)
+}
+
+// parseInline parse value following "key:" or "-" on the same line, block scalars read the following lines.
+func (p *yamlParser) parseInline(s string, l yamlLine, depth int) (interface{}, error) {
+	if s[0] == '|' || s[0] == '>' {
+		return p.parseBlockScalar(s, l)
+	}
+	if s[0] == '&' || s[0] == '*' || s[0] == '!' {
+		return nil, fmt.Errorf("%w: line %d: anchors, aliases and tags are not supported", ErrYAMLSyntax, l.num)
+	}
+	return parseYAMLValue(s, l.num)
+}
+
+// parseBlockScalar read literal "|" or folded ">" scalar from lines more indented than the line of its indicator.
+func (p *yamlParser) parseBlockScalar(header string, l yamlLine) (interface{}, error) {
+	chomp := byte(0)
+	for _, c := range header[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomp = byte(c)
+		case c >= '1' && c <= '9':
+		default:
+			return nil, fmt.Errorf("%w: line %d: invalid block scalar header", ErrYAMLSyntax, l.num)
+		}
+	}
+	var raw []string
+	indent := -1
+	for p.pos < len(p.lines) {
+		n := p.lines[p.pos]
+		if n.raw != "" && n.indent <= l.indent {
+			break
+		}
+		if n.raw != "" && indent < 0 {
+			indent = n.indent
+		}
+		raw = append(raw, n.rawFrom(indent))
+		p.pos++
+	}
+	trailing := 0
+	for len(raw) > 0 && raw[len(raw)-1] == "" {
+		raw = raw[:len(raw)-1]
+		trailing++
+	}
+	var text string
+	if header[0] == '|' {
+		text = strings.Join(raw, "\n")
+	} else {
+		var b strings.Builder
+		for i, line := range raw {
+			if i > 0 {
+				if line == "" || raw[i-1] == "" {
+					b.WriteByte('\n')
+				} else {
+					b.WriteByte(' ')
+				}
+			}
+			b.WriteString(line)
+		}
+		text = strings.ReplaceAll(b.String(), "\n\n", "\n")
+	}
+	switch chomp {
+	case '-':
+	case '+':
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		if len(raw) > 0 {
+			text += "\n"
+		}
+	}
+	return text, nil
+}
+
+// rawFrom return line content without indent columns, comments are part of block scalars.
+func (l yamlLine) rawFrom(indent int) string {
+	if l.raw == "" {
+		return ""
+	}
+	return strings.Repeat(" ", l.indent-indent) + l.raw
+}
+
+// yamlKeyEnd return index of ':' ending a mapping key, -1 if s is not a mapping entry.
+func yamlKeyEnd(s string) int {
+	if s == "" || s[0] == '[' || s[0] == '{' {
+		return -1
+	}
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case i == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t'):
+			return i
+		}
+	}
+	return -1
+}
+
+func parseYAMLKey(s string, num int) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return s, nil
+	}
+	v, err := parseYAMLValue(s, num)
+	if err != nil {
+		return "", err
+	}
+	return genericKey(v), nil
+}
+
+// parseYAMLValue parse scalar or flow collection written on a single line.
+func parseYAMLValue(s string, num int) (interface{}, error) {
+	if c := s[0]; c != '[' && c != '{' && c != '"' && c != '\'' {
+		return parseYAMLPlain(s), nil
+	}
+	f := &yamlFlow{s: s, num: num}
+	v, err := f.value(0)
+	if err != nil {
+		return nil, err
+	}
+	f.space()
+	if f.i < len(f.s) {
+		return nil, fmt.Errorf("%w: line %d: unexpected %q", ErrYAMLSyntax, num, f.s[f.i:])
+	}
+	return v, nil
+}
+
+// yamlFlow parser of flow style values, e.g. [a, {b: c}].
+type yamlFlow struct {
+	s   string
+	i   int
+	num int
+}
+
+func (f *yamlFlow) space() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrYAMLSyntax, f.num, fmt.Sprintf(format, args...))
+}
+
+func (f *yamlFlow) value(depth int) (interface{}, error) {
+	if depth > maxCodecDepth {
+		return nil, ErrCodecDepth
+	}
+	f.space()
+	if f.i >= len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		s := []interface{}{}
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return s, nil
+			}
+			v, err := f.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		m := orderedMap{}
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return m, nil
+			}
+			k, err := f.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			f.space()
+			if f.i >= len(f.s) || f.s[f.i] != ':' {
+				return nil, f.errorf("expected ':' in flow mapping")
+			}
+			f.i++
+			v, err := f.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, mapItem{genericKey(k), v})
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"':
+		return f.doubleQuoted()
+	case '\'':
+		return f.singleQuoted()
+	}
+	return f.plain(), nil
+}
+
+// separator consume ',' between flow entries, leaving the closing bracket.
+func (f *yamlFlow) separator(end byte) error {
+	f.space()
+	if f.i >= len(f.s) {
+		return f.errorf("unterminated flow collection")
+	}
+	switch f.s[f.i] {
+	case ',':
+		f.i++
+		return nil
+	case end:
+		return nil
+	}
+	return f.errorf("expected ',' or %q", end)
+}
+
+func (f *yamlFlow) doubleQuoted() (interface{}, error) {
+	start := f.i
+	for f.i++; f.i < len(f.s); f.i++ {
+		switch f.s[f.i] {
+		case '\\':
+			f.i++
+		case '"':
+			f.i++
+			v, err := strconv.Unquote(f.s[start:f.i])
+			if err != nil {
+				return nil, f.errorf("invalid double quoted string")
+			}
+			return v, nil
+		}
+	}
+	return nil, f.errorf("unterminated double quoted string")
+}
+
+func (f *yamlFlow) singleQuoted() (interface{}, error) {
+	var b strings.Builder
+	for f.i++; f.i < len(f.s); f.i++ {
+		if f.s[f.i] == '\'' {
+			if f.i+1 < len(f.s) && f.s[f.i+1] == '\'' {
+				b.WriteByte('\'')
+				f.i++
+				continue
+			}
+			f.i++
+			return b.String(), nil
+		}
+		b.WriteByte(f.s[f.i])
+	}
+	return nil, f.errorf("unterminated single quoted string")
+}
+
+// plain read plain scalar, inside flow collections it ends at ',', ']', '}' or ": ".
+func (f *yamlFlow) plain() interface{} {
+	start := f.i
+	for ; f.i < len(f.s); f.i++ {
+		c := f.s[f.i]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if c == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ') {
+			break
+		}
+	}
+	return parseYAMLPlain(strings.TrimSpace(f.s[start:f.i]))
+}
+
+// parseYAMLPlain resolve plain scalar of the YAML 1.2 core schema.
+func parseYAMLPlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if strings.HasPrefix(s, "0x") {
+		if i, err := strconv.ParseInt(s[2:], 16, 64); err == nil {
+			return i
+		}
+	}
+	if strings.HasPrefix(s, "0o") {
+		if i, err := strconv.ParseInt(s[2:], 8, 64); err == nil {
+			return i
+		}
+	}
+	if c := s[0]; c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9') {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f
+		}
+	}
+	return s
+}
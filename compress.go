@@ -0,0 +1,328 @@
+package httpserver
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+
+	defaultCompressMinSize = 1024
+)
+
+var defaultCompressContentTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/xml",
+	"text/javascript",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// CompressOpts options for response compression middleware.
+type CompressOpts struct {
+	// Level compression level, ranging from 1 (best speed) to 9 (best compression).
+	// If empty then default compression level is used.
+	Level int
+
+	// MinSize minimum response body size in bytes to be compressed.
+	// If empty then 1024 is used.
+	MinSize int
+
+	// ContentTypes list of media types allowed to be compressed, e.g. "application/json".
+	// If empty then default list of text based types is used.
+	ContentTypes []string
+}
+
+type compressor struct {
+	minSize      int
+	contentTypes map[string]struct{}
+	gzipPool     sync.Pool
+	deflatePool  sync.Pool
+}
+
+// Compress middleware compressing response body with gzip or deflate, negotiated from Accept-Encoding request header.
+// Response is left untouched if handler already set Content-Encoding, its content type is not allowed,
+// its size is smaller than minimum size, or the request asks for connection upgrade.
+// @opts: can be nil, if nil then default is used.
+func Compress(opts *CompressOpts) Middleware {
+	if opts == nil {
+		opts = &CompressOpts{}
+	}
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic("httpserver: invalid compression level " + strconv.Itoa(level))
+	}
+	c := &compressor{
+		minSize:      opts.MinSize,
+		contentTypes: make(map[string]struct{}),
+	}
+	if c.minSize <= 0 {
+		c.minSize = defaultCompressMinSize
+	}
+	contentTypes := opts.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = defaultCompressContentTypes
+	}
+	for _, v := range contentTypes {
+		c.contentTypes[strings.ToLower(strings.TrimSpace(v))] = struct{}{}
+	}
+	c.gzipPool.New = func() interface{} {
+		gw, _ := gzip.NewWriterLevel(nil, level)
+		return gw
+	}
+	c.deflatePool.New = func() interface{} {
+		fw, _ := flate.NewWriter(nil, level)
+		return fw
+	}
+	return c.middleware
+}
+
+func (c *compressor) middleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		// upgraded connections, e.g. websocket, take over the raw connection and have no body to compress.
+		if encoding == "" || r.Method == http.MethodHead || headerHasToken(r.Header, "Connection", "upgrade") {
+			next(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			c:              c,
+			encoding:       encoding,
+		}
+		// keep *responseWriter as the outermost writer so its status tracking and response helpers keep working.
+		if rw, ok := getResponseWriter(w); ok {
+			orig := rw.ResponseWriter
+			cw.ResponseWriter = orig
+			rw.ResponseWriter = cw
+			defer func() {
+				cw.Close()
+				rw.ResponseWriter = orig
+			}()
+			next(w, r)
+			return
+		}
+		defer cw.Close()
+		next(cw, r)
+	}
+}
+
+func (c *compressor) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	_, ok := c.contentTypes[mediaType]
+	return ok
+}
+
+// negotiateEncoding pick supported encoding with the highest q-value from Accept-Encoding header.
+// Return empty string if none is acceptable.
+func negotiateEncoding(acceptEncoding string) string {
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, v := range strings.Split(acceptEncoding, ",") {
+		name, q := parseQValue(v)
+		name = strings.ToLower(name)
+		if q <= 0 {
+			continue
+		}
+		switch name {
+		case encodingGzip, encodingDeflate:
+		case "*":
+			name = encodingGzip
+		default:
+			continue
+		}
+		// gzip is preferred over deflate if both have same weight.
+		if q > bestQ || (q == bestQ && name == encodingGzip) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// parseQValue split header element such as "gzip;q=0.8" into its value and weight.
+// Weight is 1 if not specified.
+func parseQValue(s string) (string, float64) {
+	parts := strings.Split(s, ";")
+	value := strings.TrimSpace(parts[0])
+	q := 1.0
+	for _, p := range parts[1:] {
+		p = strings.TrimSpace(p)
+		if !strings.HasPrefix(p, "q=") {
+			continue
+		}
+		f, err := strconv.ParseFloat(p[2:], 64)
+		if err != nil {
+			return value, 0
+		}
+		q = f
+	}
+	return value, q
+}
+
+// compressWriter buffer response body until minimum size is reached, then decide whether to compress it or not.
+type compressWriter struct {
+	http.ResponseWriter
+	c          *compressor
+	encoding   string
+	statusCode int
+	buf        []byte
+	decided    bool
+	w          io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.decided || cw.statusCode != 0 {
+		return
+	}
+	if statusCode < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.statusCode = statusCode
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.statusCode == 0 {
+		cw.statusCode = http.StatusOK
+	}
+	if cw.decided {
+		if cw.w != nil {
+			return cw.w.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.c.minSize {
+		cw.decide(true)
+		if err := cw.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Unwrap return the underlying http.ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Flush compress whatever has been buffered so far and flush it to the client.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.statusCode == 0 {
+			cw.statusCode = http.StatusOK
+		}
+		cw.decide(true)
+		cw.flushBuffer()
+	}
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close write remaining buffer and release the compressor back into the pool.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.statusCode == 0 && len(cw.buf) == 0 {
+			return nil
+		}
+		cw.decide(len(cw.buf) >= cw.c.minSize)
+		if err := cw.flushBuffer(); err != nil {
+			return err
+		}
+	}
+	if cw.w == nil {
+		return nil
+	}
+	err := cw.w.Close()
+	switch gw := cw.w.(type) {
+	case *gzip.Writer:
+		gw.Reset(nil)
+		cw.c.gzipPool.Put(gw)
+	case *flateWriter:
+		gw.Reset(nil)
+		cw.c.deflatePool.Put(gw.Writer)
+	}
+	cw.w = nil
+	return err
+}
+
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	// partial content is left untouched, its ranges refer to the identity encoded representation.
+	if compress && h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		cw.statusCode != http.StatusPartialContent && cw.c.allowed(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		// compressed body is another representation, byte ranges and strong validator of identity one don't apply.
+		h.Del("Accept-Ranges")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		switch cw.encoding {
+		case encodingGzip:
+			gw := cw.c.gzipPool.Get().(*gzip.Writer)
+			gw.Reset(cw.ResponseWriter)
+			cw.w = gw
+		case encodingDeflate:
+			fw := cw.c.deflatePool.Get().(*flate.Writer)
+			fw.Reset(cw.ResponseWriter)
+			cw.w = &flateWriter{fw}
+		}
+	}
+	if cw.statusCode != 0 {
+		cw.ResponseWriter.WriteHeader(cw.statusCode)
+	}
+}
+
+func (cw *compressWriter) flushBuffer() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.w != nil {
+		_, err = cw.w.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// flateWriter distinguish pooled deflate writer from gzip writer.
+type flateWriter struct {
+	*flate.Writer
+}
@@ -0,0 +1,157 @@
+package httpserver
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                        "",
+		"gzip":                    encodingGzip,
+		"deflate":                 encodingDeflate,
+		"gzip, deflate":           encodingGzip,
+		"gzip;q=0.5, deflate":     encodingDeflate,
+		"gzip;q=0, deflate;q=0":   "",
+		"br, *":                   encodingGzip,
+		"identity":                "",
+		"deflate;q=1.0, gzip;q=1": encodingGzip,
+	}
+	for header, expected := range cases {
+		if got := negotiateEncoding(header); got != expected {
+			t.Errorf("%s expected %q for %q, returned %q", t.Name(), expected, header, got)
+		}
+	}
+}
+
+func TestCompress_Gzip(t *testing.T) {
+	body := map[string]string{"key": strings.Repeat("value", 500)}
+	h := Compress(nil)(func(w http.ResponseWriter, r *http.Request) {
+		ResponseJSON(w, http.StatusCreated, body)
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	h(rw, r)
+
+	if w.Header().Get("Content-Encoding") != encodingGzip {
+		t.Fatalf("%s expected gzip encoding, returned %q", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("%s expected Vary header, returned %q", t.Name(), w.Header().Get("Vary"))
+	}
+	if w.Code != http.StatusCreated || rw.statusCode != http.StatusCreated {
+		t.Errorf("%s expected status %d, returned %d", t.Name(), http.StatusCreated, w.Code)
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("%s failed reading gzip body: %v", t.Name(), err)
+	}
+	b, _ := ioutil.ReadAll(gr)
+	if !strings.Contains(string(b), "valuevalue") {
+		t.Errorf("%s expected decompressed body, returned %q", t.Name(), string(b))
+	}
+}
+
+func TestCompress_Deflate(t *testing.T) {
+	h := Compress(&CompressOpts{MinSize: 10})(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("a", 100)))
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "deflate")
+	w := httptest.NewRecorder()
+	h(w, r)
+
+	if w.Header().Get("Content-Encoding") != encodingDeflate {
+		t.Fatalf("%s expected deflate encoding, returned %q", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+	b, _ := ioutil.ReadAll(flate.NewReader(w.Body))
+	if string(b) != strings.Repeat("a", 100) {
+		t.Errorf("%s expected decompressed body, returned %q", t.Name(), string(b))
+	}
+}
+
+func TestCompress_Skip(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"small", func(w http.ResponseWriter, r *http.Request) {
+			ResponseJSON(w, http.StatusOK, map[string]string{"key": "value"})
+		}},
+		{"encoded", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		}},
+		{"content-type", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		}},
+		{"range", func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("Range", "bytes=0-1999")
+			ServeFile(w, r, "a.txt", strings.NewReader(strings.Repeat("a", 4096)), time.Time{})
+		}},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		Compress(nil)(c.handler)(newResponseWriter(w, "", ""), r)
+		if w.Header().Get("Content-Encoding") == encodingGzip {
+			t.Errorf("%s/%s expected response not compressed", t.Name(), c.name)
+		}
+	}
+}
+
+func TestCompress_Flush(t *testing.T) {
+	h := Compress(nil)(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h(w, r)
+	if !w.Flushed {
+		t.Errorf("%s expected underlying writer flushed", t.Name())
+	}
+	if w.Header().Get("Content-Encoding") != encodingGzip {
+		t.Errorf("%s expected gzip encoding on flush, returned %q", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompress_ServeFile(t *testing.T) {
+	h := Compress(nil)(func(w http.ResponseWriter, r *http.Request) {
+		ServeFile(w, r, "a.txt", strings.NewReader(strings.Repeat("a", 4096)), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h(newResponseWriter(w, "", ""), r)
+	if w.Header().Get("Content-Encoding") != encodingGzip {
+		t.Fatalf("%s expected gzip encoding, returned %v", t.Name(), w.Header())
+	}
+	if etag := w.Header().Get("ETag"); !strings.HasPrefix(etag, `W/"`) || w.Header().Get("Accept-Ranges") != "" {
+		t.Errorf("%s expected weak etag without accept ranges, returned %v", t.Name(), w.Header())
+	}
+
+	// weak etag of compressed response still revalidates.
+	etag := w.Header().Get("ETag")
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h(newResponseWriter(w, "", ""), r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("%s expected %d for %s, returned %d", t.Name(), http.StatusNotModified, etag, w.Code)
+	}
+}
@@ -0,0 +1,281 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	csrfTokenLength = 32
+
+	defaultCSRFCookieName = "_csrf"
+	defaultCSRFHeaderName = "X-Csrf-Token"
+	defaultCSRFFormField  = "csrf_token"
+
+	// csrfMaxPeek maximum bytes of multipart body read looking for token field.
+	csrfMaxPeek = 8 << 10
+
+	// CSRFTemplateFunc name of template function rendering CSRF token in ResponseHTML and ResponseMultiHTML.
+	CSRFTemplateFunc = "csrfToken"
+)
+
+type csrfTokenKey struct{}
+
+type csrfContext struct {
+	token string
+	field string
+}
+
+// CSRFOpts options for CSRF protection middleware.
+type CSRFOpts struct {
+	// CookieName cookie storing the token. If empty then "_csrf" is used.
+	CookieName string
+
+	// CookiePath if empty then "/" is used.
+	CookiePath string
+
+	// CookieDomain optional.
+	CookieDomain string
+
+	// MaxAge cookie max age in seconds. If empty then cookie lasts for browser session.
+	MaxAge int
+
+	// Secure set Secure flag on cookie. Always set if request comes through TLS.
+	Secure bool
+
+	// HeaderName request header carrying the token, used by scripts. If empty then "X-Csrf-Token" is used.
+	HeaderName string
+
+	// FormField form field carrying the token, used by html forms. If empty then "csrf_token" is used.
+	// In multipart forms it must be the first field, so the body can still be streamed, e.g. by ParseUpload.
+	FormField string
+
+	// TrustedOrigins origins other than the request host allowed to submit requests, e.g. "https://example.com".
+	TrustedOrigins []string
+
+	// ExemptPaths path prefixes not checked, e.g. API groups authenticated by token rather than cookie.
+	ExemptPaths []string
+
+	// Skip optional, requests for which it returns true are not checked.
+	Skip func(r *http.Request) bool
+
+	// Failure optional, triggered if check failed. If empty then default 403 response is used.
+	Failure http.HandlerFunc
+}
+
+// CSRF middleware protecting unsafe requests against cross-site request forgery using double-submit cookie pattern.
+// Token is available in handlers via CSRFToken, and in templates rendered by ResponseHTML and ResponseMultiHTML
+// via `{{ csrfToken }}` template function.
+// @opts: can be nil, if nil then default is used.
+func CSRF(opts *CSRFOpts) Middleware {
+	if opts == nil {
+		opts = &CSRFOpts{}
+	}
+	o := *opts
+	if o.CookieName == "" {
+		o.CookieName = defaultCSRFCookieName
+	}
+	if o.CookiePath == "" {
+		o.CookiePath = "/"
+	}
+	if o.HeaderName == "" {
+		o.HeaderName = defaultCSRFHeaderName
+	}
+	if o.FormField == "" {
+		o.FormField = defaultCSRFFormField
+	}
+	trusted := make(map[string]struct{})
+	for _, v := range o.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimSuffix(v, "/"))] = struct{}{}
+	}
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if o.Skip != nil && o.Skip(r) {
+				next(w, r)
+				return
+			}
+			for _, v := range o.ExemptPaths {
+				if strings.HasPrefix(r.URL.Path, v) {
+					next(w, r)
+					return
+				}
+			}
+
+			w.Header().Add("Vary", "Cookie")
+			token := csrfCookieToken(r, o.CookieName)
+			if !csrfSafeMethod(r.Method) {
+				if token == nil || !csrfOriginAllowed(r, trusted) || !csrfTokenMatch(token, csrfRequestToken(r, o.HeaderName, o.FormField)) {
+					csrfFailure(w, r, o.Failure)
+					return
+				}
+			}
+			if token == nil {
+				token = make([]byte, csrfTokenLength)
+				if _, err := rand.Read(token); err != nil {
+					csrfFailure(w, r, o.Failure)
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name:     o.CookieName,
+					Value:    base64.RawURLEncoding.EncodeToString(token),
+					Path:     o.CookiePath,
+					Domain:   o.CookieDomain,
+					MaxAge:   o.MaxAge,
+					Secure:   o.Secure || r.TLS != nil,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+
+			masked := maskCSRFToken(token)
+			addTemplateFunc(w, CSRFTemplateFunc, func() string { return masked })
+			next(w, r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, &csrfContext{masked, o.FormField})))
+		}
+	}
+}
+
+// CSRFToken return CSRF token of current request to be embedded into forms or sent by scripts.
+// Return empty string if CSRF middleware is not used.
+func CSRFToken(r *http.Request) string {
+	c, ok := r.Context().Value(csrfTokenKey{}).(*csrfContext)
+	if !ok {
+		return ""
+	}
+	return c.token
+}
+
+// CSRFField return hidden form input holding CSRF token of current request.
+// Return empty string if CSRF middleware is not used.
+func CSRFField(r *http.Request) template.HTML {
+	c, ok := r.Context().Value(csrfTokenKey{}).(*csrfContext)
+	if !ok {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(c.field) + `" value="` + template.HTMLEscapeString(c.token) + `">`)
+}
+
+func csrfFailure(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	if handler != nil {
+		handler(w, r)
+		return
+	}
+	ResponseString(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func csrfCookieToken(r *http.Request, name string) []byte {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	token, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil || len(token) != csrfTokenLength {
+		return nil
+	}
+	return token
+}
+
+func csrfRequestToken(r *http.Request, header, field string) string {
+	if v := r.Header.Get(header); v != "" {
+		return v
+	}
+	if mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "multipart/form-data" {
+		return csrfMultipartToken(r, params["boundary"], field)
+	}
+	return r.PostFormValue(field)
+}
+
+// csrfMultipartToken read token from the first part of multipart body without parsing the whole form.
+// Bytes read are put back into body, so handlers see it untouched.
+func csrfMultipartToken(r *http.Request, boundary string, field string) string {
+	if r.Body == nil || boundary == "" {
+		return ""
+	}
+	var peeked bytes.Buffer
+	body := r.Body
+	defer func() {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(peeked.Bytes()), body), body}
+	}()
+	mr := multipart.NewReader(io.TeeReader(io.LimitReader(body, csrfMaxPeek), &peeked), boundary)
+	part, err := mr.NextPart()
+	if err != nil || part.FormName() != field || part.FileName() != "" {
+		return ""
+	}
+	token, err := ioutil.ReadAll(io.LimitReader(part, csrfMaxPeek))
+	if err != nil {
+		return ""
+	}
+	return string(token)
+}
+
+// csrfOriginAllowed check Origin header, or Referer if Origin is absent, against client scheme and host and trusted origins.
+// Request without both headers is allowed, token check still applies.
+func csrfOriginAllowed(r *http.Request, trusted map[string]struct{}) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			// nothing to check against, rely on token check only.
+			return origin == ""
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Scheme, ClientScheme(r)) && strings.EqualFold(u.Host, ClientHost(r)) {
+		return true
+	}
+	_, ok := trusted[strings.ToLower(u.Scheme+"://"+u.Host)]
+	return ok
+}
+
+// maskCSRFToken xor token with one-time pad so rendered token differs on each response (BREACH mitigation).
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*len(token))
+	pad := masked[:len(token)]
+	rand.Read(pad)
+	for i := range token {
+		masked[len(token)+i] = pad[i] ^ token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func csrfTokenMatch(token []byte, sent string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(masked) != 2*len(token) {
+		return false
+	}
+	unmasked := make([]byte, len(token))
+	for i := range token {
+		unmasked[i] = masked[i] ^ masked[len(token)+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, token) == 1
+}
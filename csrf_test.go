@@ -0,0 +1,158 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCSRF_IssueToken(t *testing.T) {
+	var token string
+	h := CSRF(nil)(func(w http.ResponseWriter, r *http.Request) {
+		token = CSRFToken(r)
+		ResponseHTML(w, "form", `<input name="csrf_token" value="{{ csrfToken }}">`, nil)
+	})
+	w := httptest.NewRecorder()
+	h(newResponseWriter(w, "", ""), httptest.NewRequest(http.MethodGet, "/form", nil))
+
+	if token == "" {
+		t.Fatalf("%s expected token in context", t.Name())
+	}
+	if len(w.Result().Cookies()) != 1 || w.Result().Cookies()[0].Name != defaultCSRFCookieName {
+		t.Fatalf("%s expected csrf cookie set", t.Name())
+	}
+	if !strings.Contains(w.Body.String(), `value="`+token+`"`) {
+		t.Errorf("%s expected token rendered, returned %s", t.Name(), w.Body.String())
+	}
+}
+
+func TestCSRF_Verify(t *testing.T) {
+	m := CSRF(&CSRFOpts{ExemptPaths: []string{"/api"}, TrustedOrigins: []string{"https://trusted.com"}})
+
+	// obtain cookie and token first.
+	var token string
+	w := httptest.NewRecorder()
+	m(func(w http.ResponseWriter, r *http.Request) { token = CSRFToken(r) })(newResponseWriter(w, "", ""), httptest.NewRequest(http.MethodGet, "/form", nil))
+	cookie := w.Result().Cookies()[0]
+
+	cases := []struct {
+		name     string
+		path     string
+		origin   string
+		cookie   bool
+		token    string
+		expected int
+	}{
+		{"valid form", "/form", "", true, token, http.StatusOK},
+		{"same origin", "/form", "http://example.com", true, token, http.StatusOK},
+		{"trusted origin", "/form", "https://trusted.com", true, token, http.StatusOK},
+		{"cross origin", "/form", "https://evil.com", true, token, http.StatusForbidden},
+		{"other scheme", "/form", "https://example.com", true, token, http.StatusForbidden},
+		{"missing token", "/form", "", true, "", http.StatusForbidden},
+		{"missing cookie", "/form", "", false, token, http.StatusForbidden},
+		{"wrong token", "/form", "", true, maskCSRFToken(make([]byte, csrfTokenLength)), http.StatusForbidden},
+		{"exempt path", "/api/items", "https://evil.com", false, "", http.StatusOK},
+	}
+	for _, c := range cases {
+		form := url.Values{defaultCSRFFormField: {c.token}}
+		r := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if c.cookie {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		m(func(w http.ResponseWriter, r *http.Request) {})(newResponseWriter(w, "", ""), r)
+		if w.Code != c.expected {
+			t.Errorf("%s/%s expected %d, returned %d", t.Name(), c.name, c.expected, w.Code)
+		}
+	}
+}
+
+func TestCSRF_HeaderToken(t *testing.T) {
+	var token string
+	m := CSRF(nil)
+	w := httptest.NewRecorder()
+	m(func(w http.ResponseWriter, r *http.Request) { token = CSRFToken(r) })(newResponseWriter(w, "", ""), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	r := httptest.NewRequest(http.MethodDelete, "/items/1", nil)
+	r.AddCookie(w.Result().Cookies()[0])
+	r.Header.Set(defaultCSRFHeaderName, token)
+	w = httptest.NewRecorder()
+	m(func(w http.ResponseWriter, r *http.Request) {})(newResponseWriter(w, "", ""), r)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+}
+
+func TestCSRFField(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if CSRFField(r) != "" {
+		t.Errorf("%s expected empty field without middleware", t.Name())
+	}
+}
+
+func TestCSRF_Multipart(t *testing.T) {
+	var token string
+	m := CSRF(nil)
+	w := httptest.NewRecorder()
+	m(func(w http.ResponseWriter, r *http.Request) { token = CSRFToken(r) })(newResponseWriter(w, "", ""), httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := w.Result().Cookies()[0]
+
+	dir, _ := ioutil.TempDir("", "csrf-upload-test")
+	defer os.RemoveAll(dir)
+	var u *Upload
+	h := m(UploadMiddleware(&UploadOpts{TempDir: dir})(func(w http.ResponseWriter, r *http.Request) {
+		u, _ = GetUpload(r)
+	}))
+	cases := []struct {
+		token    string
+		expected int
+	}{
+		{token, http.StatusOK},
+		{"", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		u = nil
+		r := testMultipartRequest(map[string][]byte{"avatar": testPNG}, map[string]string{defaultCSRFFormField: c.token})
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		h(newResponseWriter(w, "", ""), r)
+		if w.Code != c.expected {
+			t.Errorf("%s expected %d for token %q, returned %d", t.Name(), c.expected, c.token, w.Code)
+		}
+		if c.expected == http.StatusOK && (u == nil || u.File("avatar") == nil || u.Values.Get(defaultCSRFFormField) != token) {
+			t.Errorf("%s expected upload streamed after token check, returned %+v", t.Name(), u)
+		}
+	}
+}
+
+func TestCSRF_ProxyOrigin(t *testing.T) {
+	srv := New(&Opts{TrustedProxies: []string{"192.0.2.0/24"}})
+	var token string
+	srv.GET("/form", func(w http.ResponseWriter, r *http.Request) { token = CSRFToken(r) }, CSRF(nil))
+	srv.POST("/form", func(w http.ResponseWriter, r *http.Request) {}, CSRF(nil))
+	srv.build()
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	cookie := w.Result().Cookies()[0]
+
+	r := httptest.NewRequest(http.MethodPost, "/form", nil)
+	r.Host = "backend:8080"
+	r.Header.Set("X-Forwarded-Host", "www.example.com")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("Origin", "https://www.example.com")
+	r.Header.Set(defaultCSRFHeaderName, token)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected origin of forwarded host allowed, returned %d", t.Name(), w.Code)
+	}
+}
@@ -111,17 +111,24 @@ func Handler1(w http.ResponseWriter, r *http.Request) {
 	_httpserver.ResponseString(w, http.StatusOK, fmt.Sprintf("Handler1: %s | %s", allHeaders, allParams))
 }
 
+type Handler2Request struct {
+	Key1 string `json:"key1" form:"key1"`
+	Key2 string `json:"key2" form:"key2"`
+}
+
 func Handler2(w http.ResponseWriter, r *http.Request) {
 	allHeaders := r.Header
 	allParams := r.URL.RawQuery
-	r.ParseForm()
-	b1 := r.FormValue("key1")
-	b2 := r.FormValue("key2")
+	var req Handler2Request
+	if err := _httpserver.Bind(r, &req); err != nil {
+		_httpserver.ResponseJSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
 	resp := make(map[string]interface{})
 	resp["headers"] = allHeaders
 	resp["params"] = allParams
-	resp["body1"] = b1
-	resp["body2"] = b2
+	resp["body1"] = req.Key1
+	resp["body2"] = req.Key2
 	_httpserver.ResponseJSON(w, http.StatusOK, resp)
 }
 
@@ -0,0 +1,119 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ResponseFile response with content shown inline by browser, see ServeFile.
+// Call at the end line of your handler.
+func ResponseFile(w http.ResponseWriter, r *http.Request, name string, content io.ReadSeeker, modtime time.Time) {
+	w.Header().Set("Content-Disposition", ContentDisposition("inline", name))
+	ServeFile(w, r, name, content, modtime)
+}
+
+// ResponseAttachment response with content downloaded by browser as file, see ServeFile.
+// Call at the end line of your handler.
+func ResponseAttachment(w http.ResponseWriter, r *http.Request, name string, content io.ReadSeeker, modtime time.Time) {
+	w.Header().Set("Content-Disposition", ContentDisposition("attachment", name))
+	ServeFile(w, r, name, content, modtime)
+}
+
+// ServeFile response with content using http.ServeContent, supporting byte ranges, multipart ranges and conditional requests.
+// ETag is generated from modtime and size, or from content hash if modtime is zero, unless handler set one already.
+// Content-Type is detected from extension of name, or from content, unless handler set one already.
+// @name: file name, path is allowed, only its base is used for Content-Disposition.
+// @modtime: last modification time, can be zero, if zero then Last-Modified is not sent.
+func ServeFile(w http.ResponseWriter, r *http.Request, name string, content io.ReadSeeker, modtime time.Time) {
+	if w.Header().Get("ETag") == "" {
+		etag, err := fileETag(content, modtime)
+		if err != nil {
+			ResponseString(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		w.Header().Set("ETag", etag)
+	}
+	http.ServeContent(w, r, name, modtime, content)
+}
+
+// fileETag strong validator of content, leaving content at its start.
+func fileETag(content io.ReadSeeker, modtime time.Time) (string, error) {
+	if modtime.IsZero() || modtime.Unix() == 0 {
+		h := sha256.New()
+		if _, err := io.Copy(h, content); err != nil {
+			return "", err
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+	}
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%x-%x"`, modtime.UnixNano(), size), nil
+}
+
+// ContentDisposition format Content-Disposition header value of disposition type with filename, see RFC 6266.
+// Filename not representable as plain ascii is also sent as filename* in UTF-8, filename holds ascii fallback for older clients.
+// @name: file name, path is allowed, only its base is used. If empty then only disposition type is returned.
+func ContentDisposition(dispositionType string, name string) string {
+	name = path.Base(strings.Replace(name, `\`, "/", -1))
+	if name == "." || name == "/" {
+		return dispositionType
+	}
+	// fallback replace characters quoted-string can't carry reliably, filename* keeps the exact name.
+	var (
+		fallback strings.Builder
+		exact    = true
+	)
+	for _, c := range name {
+		if c >= utf8.RuneSelf || c < 0x20 || c == 0x7f || c == '"' || c == '\\' {
+			exact = false
+			fallback.WriteByte('_')
+			continue
+		}
+		fallback.WriteRune(c)
+	}
+	v := dispositionType + `; filename="` + fallback.String() + `"`
+	if !exact {
+		v += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+	return v
+}
+
+// encodeExtValue percent encode s as ext-value, see RFC 8187 section 3.2.
+func encodeExtValue(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0x0f])
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"`},
+		{"/tmp/exports/a b.csv", `attachment; filename="a b.csv"`},
+		{`C:\exports\say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{"€ rates.txt", `attachment; filename="_ rates.txt"; filename*=UTF-8''%E2%82%AC%20rates.txt`},
+		{"", `attachment`},
+	}
+	for _, tt := range tests {
+		if v := ContentDisposition("attachment", tt.name); v != tt.expected {
+			t.Errorf("%s expected %s for %q, returned %s", t.Name(), tt.expected, tt.name, v)
+		}
+	}
+}
+
+func TestResponseFile(t *testing.T) {
+	modtime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	srv := New(&Opts{})
+	srv.GET("/report", func(w http.ResponseWriter, r *http.Request) {
+		ResponseAttachment(w, r, "report.txt", strings.NewReader("0123456789"), modtime)
+	})
+	srv.GET("/inline", func(w http.ResponseWriter, r *http.Request) {
+		ResponseFile(w, r, "notes.txt", strings.NewReader("0123456789"), time.Time{})
+	})
+	srv.build()
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest("GET", "/report", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" || etag == "" || w.Header().Get("Request-Id") == "" {
+		t.Fatalf("%s expected full content with etag and request id, returned %d %v", t.Name(), w.Code, w.Header())
+	}
+	if w.Header().Get("Content-Disposition") != `attachment; filename="report.txt"` || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("%s expected attachment of text, returned %v", t.Name(), w.Header())
+	}
+
+	tests := []struct {
+		header      string
+		value       string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"Range", "bytes=2-4", http.StatusPartialContent, "text/plain", "234"},
+		{"Range", "bytes=0-0,-2", http.StatusPartialContent, "multipart/byteranges", "Content-Range: bytes 8-9/10"},
+		{"Range", "bytes=20-", http.StatusRequestedRangeNotSatisfiable, "", ""},
+		{"If-None-Match", etag, http.StatusNotModified, "", ""},
+		{"If-Modified-Since", modtime.Format(http.TimeFormat), http.StatusNotModified, "", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/report", nil)
+		r.Header.Set(tt.header, tt.value)
+		srv.handlers.ServeHTTP(w, r)
+		if w.Code != tt.status || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s expected %d %s %q for %s: %s, returned %d %s %q", t.Name(), tt.status, tt.contentType, tt.body,
+				tt.header, tt.value, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+		if w.Header().Get("Request-Id") == "" {
+			t.Errorf("%s expected request id for %s: %s", t.Name(), tt.header, tt.value)
+		}
+	}
+
+	// without modtime etag is content hash, stable across requests.
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest("GET", "/inline", nil))
+	etag = w.Header().Get("ETag")
+	if w.Header().Get("Content-Disposition") != `inline; filename="notes.txt"` || w.Header().Get("Last-Modified") != "" || etag == "" {
+		t.Errorf("%s expected inline without last modified, returned %v", t.Name(), w.Header())
+	}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/inline", nil)
+	r.Header.Set("If-None-Match", etag)
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("%s expected 304 for content hash etag, returned %d", t.Name(), w.Code)
+	}
+}
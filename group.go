@@ -20,31 +20,31 @@ func (s *Server) Group(prefix string, middlewares ...Middleware) *Group {
 }
 
 func (g *Group) GET(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.GET(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+	g.server.handle(http.MethodGet, fmt.Sprintf("%s%s", g.prefix, path), handler, g, middlewares)
 }
 
 func (g *Group) HEAD(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.HEAD(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+	g.server.handle(http.MethodHead, fmt.Sprintf("%s%s", g.prefix, path), handler, g, middlewares)
 }
 
 func (g *Group) POST(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.POST(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+	g.server.handle(http.MethodPost, fmt.Sprintf("%s%s", g.prefix, path), handler, g, middlewares)
 }
 
 func (g *Group) PUT(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.PUT(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+	g.server.handle(http.MethodPut, fmt.Sprintf("%s%s", g.prefix, path), handler, g, middlewares)
 }
 
 func (g *Group) DELETE(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.DELETE(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+	g.server.handle(http.MethodDelete, fmt.Sprintf("%s%s", g.prefix, path), handler, g, middlewares)
 }
 
 func (g *Group) PATCH(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.PATCH(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+	g.server.handle(http.MethodPatch, fmt.Sprintf("%s%s", g.prefix, path), handler, g, middlewares)
 }
 
 func (g *Group) OPTIONS(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.OPTIONS(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+	g.server.handle(http.MethodOptions, fmt.Sprintf("%s%s", g.prefix, path), handler, g, middlewares)
 }
 
 // FILES serve files from 1 directory dynamically in a group path.
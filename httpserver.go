@@ -1,15 +1,19 @@
 package httpserver
 
 import (
+	"context"
 	"crypto/tls"
+	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"sync"
 	"time"
 
-	_uuid "github.com/google/uuid"
 	_router "github.com/julienschmidt/httprouter"
 	_cors "github.com/rs/cors"
 )
@@ -25,10 +29,36 @@ type Server struct {
 	cors        *_cors.Cors
 	middlewares []Middleware
 
+	// mu guards middlewares, routes and started against late registration.
+	mu      sync.Mutex
+	routes  []*route
+	started bool
+
+	// trustedProxies networks allowed to set forwarding headers.
+	trustedProxies []*net.IPNet
+
+	// requestID request id generation config, default is used if nil.
+	requestID *requestIDConfig
+
+	// maintenance state of maintenance mode, switched at runtime.
+	maintenance maintenance
+
 	panicHandler    PanicHandler
 	notFoundHandler http.Handler
+
+	// problemDetails respond default errors with problem details.
+	problemDetails bool
+
+	// shutdown closed once server starts shutting down.
+	shutdown shutdown
+
+	// ws websocket options and open connections, closed on shutdown.
+	ws wsRegistry
 }
 
+// errChanBuffer errors kept for ListenError, registration errors beyond it are only logged if nobody reads them.
+const errChanBuffer = 8
+
 type Middleware func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc
 type PanicHandler func(w http.ResponseWriter, r *http.Request, rcv ...interface{})
 
@@ -57,6 +87,22 @@ type Opts struct {
 	// NotFoundHandler triggered if path not found.
 	// If empty then default is used.
 	NotFoundHandler http.HandlerFunc
+
+	// TrustedProxies CIDRs or IPs of proxies allowed to set Forwarded, X-Forwarded-* and X-Real-IP headers.
+	// If empty then those headers are ignored and client address is taken from the connection.
+	TrustedProxies []string
+
+	// RequestID optional, request id generation and propagation config.
+	// If nil then incoming Request-Id or X-Request-Id is reused, otherwise UUIDv4 is generated.
+	RequestID *RequestIDOpts
+
+	// ProblemDetails respond not found, method not allowed and panic with problem details, see ResponseProblem.
+	// Custom NotFoundHandler and PanicHandler take precedence.
+	ProblemDetails bool
+
+	// WebSocket options of websocket endpoints registered with WS.
+	// If nil then default is used.
+	WebSocket *WSOpts
 }
 
 // Cors corst options
@@ -98,25 +144,33 @@ func New(opts *Opts) *Server {
 		middlewares:     make([]Middleware, 0),
 		tls:             opts.TLS,
 		cors:            cors,
-		errChan:         make(chan error),
+		errChan:         make(chan error, errChanBuffer),
 		panicHandler:    opts.PanicHandler,
 		notFoundHandler: notFoundHandler,
+		trustedProxies:  parseTrustedProxies(opts.TrustedProxies),
+		requestID:       newRequestIDConfig(opts.RequestID),
+		ws:              wsRegistry{opts: opts.WebSocket},
 	}
 	if opts.LogWriter != nil {
 		srv.logWriter = opts.LogWriter
 	}
+	if opts.ProblemDetails {
+		srv.enableProblemDetails()
+	}
 	if opts.EnableLogger {
 		buff := make(buffer, 10<<20)
 		go write(buff, srv.logWriter)
 		srv.logger = log.New(buff, "", 0)
-		srv.middlewares = append(srv.middlewares, srv.log)
+		srv.middlewares = append(srv.middlewares, Named(LoggerMiddleware, srv.log))
 	}
 	return srv
 }
 
 // Run the server. Blocking.
+// Handler chains of all routes are built here, so middlewares registered after routes still apply to them.
 func (s *Server) Run() {
 	s.logger.Printf("%s | httpserver | server is starting...", time.Now().Format(time.RFC3339))
+	s.build()
 	s.logger.Printf("%s | httpserver | server is running on port %d", time.Now().Format(time.RFC3339), s.port)
 	if err := s.serve(); err != nil {
 		s.logger.Printf("%s | httpserver | server failed with error: %v", time.Now().Format(time.RFC3339), err)
@@ -136,6 +190,77 @@ func (n *notFound) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	n.handler(w, r)
 }
 
+// addTemplateFunc register template function for current request, available in ResponseHTML and ResponseMultiHTML.
+func addTemplateFunc(w http.ResponseWriter, name string, fn interface{}) {
+	rw, ok := getResponseWriter(w)
+	if !ok {
+		return
+	}
+	if rw.templateFuncs == nil {
+		rw.templateFuncs = make(template.FuncMap)
+	}
+	rw.templateFuncs[name] = fn
+}
+
+// beforeWriteHeader register fn to run right before response header is sent, while headers can still be modified.
+// Return false if w doesn't come from handler served by this package.
+func beforeWriteHeader(w http.ResponseWriter, fn func()) bool {
+	rw, ok := getResponseWriter(w)
+	if !ok {
+		return false
+	}
+	rw.beforeHeader = append(rw.beforeHeader, fn)
+	return true
+}
+
+type shutdownKey struct{}
+
+// shutdown channel closed once, created lazily so zero value is usable.
+type shutdown struct {
+	once      sync.Once
+	closeOnce sync.Once
+	ch        chan struct{}
+}
+
+func (sd *shutdown) done() chan struct{} {
+	sd.once.Do(func() {
+		sd.ch = make(chan struct{})
+	})
+	return sd.ch
+}
+
+func (sd *shutdown) close() {
+	ch := sd.done()
+	sd.closeOnce.Do(func() {
+		close(ch)
+	})
+}
+
+// Done return channel closed when server starts shutting down, long lived responses such as SSE streams end then.
+func (s *Server) Done() <-chan struct{} {
+	return s.shutdown.done()
+}
+
+// baseContext context of all requests, carrying shutdown channel of the server.
+func (s *Server) baseContext(net.Listener) context.Context {
+	return context.WithValue(context.Background(), shutdownKey{}, s.Done())
+}
+
+// watchShutdown close Done channel once one of sigs is received.
+func (s *Server) watchShutdown(sigs ...os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	<-c
+	signal.Stop(c)
+	s.shutdown.close()
+}
+
+// serverDone return shutdown channel of server handling the request, nil if unknown.
+func serverDone(ctx context.Context) <-chan struct{} {
+	done, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return done
+}
+
 // TLSConfig generate certificate config using provided certificate and private key.
 // It will overwrite the one set in Opts.
 func (s *Server) TLSConfig(cert, key string) error {
@@ -154,26 +279,120 @@ type responseWriter struct {
 	statusCode int
 	requestID  string
 	xRequestID string
+
+	// requestIDHeader response header carrying requestID.
+	requestIDHeader string
+
+	// wroteHeader whether header has been sent to client.
+	wroteHeader bool
+
+	// written number of body bytes written.
+	written int64
+
+	// hijacked whether connection has been taken over by handler.
+	hijacked bool
+
+	// templateFuncs per request template functions, e.g. csrfToken, injected into html render helpers.
+	templateFuncs template.FuncMap
+
+	// beforeHeader hooks run right before header is sent, e.g. to set session cookie.
+	beforeHeader []func()
 }
 
+// WriteHeader inject Date and request id headers before sending header to client.
+// Subsequent calls are ignored.
 func (rw *responseWriter) WriteHeader(statusCode int) {
-	rw.statusCode = statusCode
+	if rw.wroteHeader || rw.hijacked {
+		return
+	}
+	rw.prepareHeader(statusCode)
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
+// prepareHeader run hooks and inject headers of response with status code, which is considered sent afterwards.
+// Used directly when response header is written on hijacked connection, e.g. by websocket handshake.
+func (rw *responseWriter) prepareHeader(statusCode int) {
+	rw.wroteHeader = true
+	rw.statusCode = statusCode
+	for _, fn := range rw.beforeHeader {
+		fn()
+	}
+	h := rw.ResponseWriter.Header()
+	if h.Get("Date") == "" {
+		h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	header := rw.requestIDHeader
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	if rw.requestID != "" {
+		h.Set(header, rw.requestID)
+	}
+	if rw.xRequestID != "" && header != "X-Request-Id" {
+		h.Set("X-Request-Id", rw.xRequestID)
+	}
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+// Unwrap return the underlying http.ResponseWriter.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// base return rw itself, also promoted to the writers exposing optional interfaces of rw.
+func (rw *responseWriter) base() *responseWriter {
+	return rw
+}
+
+// getResponseWriter find *responseWriter in w, following Unwrap of writers wrapping it.
+func getResponseWriter(w http.ResponseWriter) (*responseWriter, bool) {
+	for {
+		switch v := w.(type) {
+		case interface{ base() *responseWriter }:
+			return v.base(), true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
 func newResponseWriter(w http.ResponseWriter, reqID string, xReqID string) *responseWriter {
 	// default if not set is 200
-	return &responseWriter{w, http.StatusOK, reqID, xReqID}
+	return &responseWriter{
+		ResponseWriter: w,
+		statusCode:     http.StatusOK,
+		requestID:      reqID,
+		xRequestID:     xReqID,
+	}
 }
 
-func f(next http.HandlerFunc) _router.Handle {
+func f(next http.HandlerFunc, rid *requestIDConfig) _router.Handle {
+	if rid == nil {
+		rid = defaultRequestID
+	}
 	return func(w http.ResponseWriter, r *http.Request, ps _router.Params) {
-		if r.Header.Get("Request-Id") == "" && r.Header.Get("X-Request-Id") == "" {
-			r.Header.Set("Request-Id", _uuid.New().String())
+		id := rid.incoming(r.Header.Get)
+		if id == "" {
+			id = rid.generate()
 		}
-		if r.Header.Get("Request-Id") == "" && r.Header.Get("X-Request-Id") != "" {
-			r.Header.Set("Request-Id", r.Header.Get("X-Request-Id"))
+		var xReqID string
+		if x := r.Header.Get("X-Request-Id"); rid.trust && rid.valid(x) {
+			xReqID = x
 		}
+		r.Header.Set(rid.header, id)
 		if len(ps) > 0 {
 			urlValues := r.URL.Query()
 			for i := range ps {
@@ -181,8 +400,13 @@ func f(next http.HandlerFunc) _router.Handle {
 			}
 			r.URL.RawQuery = urlValues.Encode()
 		}
-		rw := newResponseWriter(w, r.Header.Get("Request-Id"), r.Header.Get("X-Request-Id"))
-		next(rw, r)
+		rw := newResponseWriter(w, id, xReqID)
+		rw.requestIDHeader = rid.header
+		ctx := WithRequestID(r.Context(), id)
+		if len(ps) > 0 {
+			ctx = context.WithValue(ctx, pathParamsKey{}, ps)
+		}
+		next(wrapResponseWriter(rw), r.WithContext(ctx))
 	}
 }
 
@@ -192,13 +416,17 @@ func (s *Server) recoverPanic(next http.HandlerFunc) http.HandlerFunc {
 			if rcv := recover(); rcv != nil {
 				if s.panicHandler != nil {
 					s.panicHandler(w, r, rcv)
-				} else {
-					ResponseString(w, http.StatusInternalServerError, "httpserver got panic")
+				} else if !HeaderWritten(w) {
+					if s.problemDetails {
+						ResponseProblem(w, r, NewProblem(http.StatusInternalServerError, ""))
+					} else {
+						ResponseString(w, http.StatusInternalServerError, "httpserver got panic")
+					}
 				}
-				s.logger.Printf("%s | httpserver | %s | %s | %s | %s\n", time.Now().Format(time.RFC3339), "PANIC", r.Method, r.URL.Path, r.Header.Get("Request-Id"))
-				s.logger.Printf("☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC START (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️", r.Header.Get("Request-Id"))
+				s.logger.Printf("%s | httpserver | %s | %s | %s | %s\n", time.Now().Format(time.RFC3339), "PANIC", r.Method, r.URL.Path, RequestID(r.Context()))
+				s.logger.Printf("☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC START (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️", RequestID(r.Context()))
 				debug.PrintStack()
-				s.logger.Printf("☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC END (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️", r.Header.Get("Request-Id"))
+				s.logger.Printf("☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC END (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️", RequestID(r.Context()))
 				return
 			}
 		}()
@@ -207,31 +435,31 @@ func (s *Server) recoverPanic(next http.HandlerFunc) http.HandlerFunc {
 }
 
 func (s *Server) GET(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.GET(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+	s.handle(http.MethodGet, path, handler, nil, middlewares)
 }
 
 func (s *Server) HEAD(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.HEAD(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+	s.handle(http.MethodHead, path, handler, nil, middlewares)
 }
 
 func (s *Server) POST(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.POST(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+	s.handle(http.MethodPost, path, handler, nil, middlewares)
 }
 
 func (s *Server) PUT(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.PUT(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+	s.handle(http.MethodPut, path, handler, nil, middlewares)
 }
 
 func (s *Server) DELETE(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.DELETE(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+	s.handle(http.MethodDelete, path, handler, nil, middlewares)
 }
 
 func (s *Server) PATCH(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.PATCH(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+	s.handle(http.MethodPatch, path, handler, nil, middlewares)
 }
 
 func (s *Server) OPTIONS(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.OPTIONS(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+	s.handle(http.MethodOptions, path, handler, nil, middlewares)
 }
 
 // FILES serve files from 1 directory dynamically.
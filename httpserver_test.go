@@ -56,12 +56,66 @@ func TestWriteHeader(t *testing.T) {
 	rw.WriteHeader(200)
 }
 
+func TestWriteHeader_InjectHeaders(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("test"))
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/health-check", nil)
+	f(next, nil)(w, r, nil)
+	if w.Code != http.StatusCreated {
+		t.Errorf("%s expected status %d, returned %d", t.Name(), http.StatusCreated, w.Code)
+	}
+	if w.Header().Get("Request-Id") == "" || w.Header().Get("Request-Id") != r.Header.Get("Request-Id") {
+		t.Errorf("%s expected Request-Id response header %s, returned %s", t.Name(), r.Header.Get("Request-Id"), w.Header().Get("Request-Id"))
+	}
+	if w.Header().Get("Date") == "" {
+		t.Errorf("%s expected Date header not empty, found empty", t.Name())
+	}
+}
+
+func TestWriteHeader_Write(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test"))
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/health-check", nil)
+	f(next, nil)(w, r, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected status %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+	if w.Header().Get("Request-Id") == "" {
+		t.Errorf("%s expected Request-Id response header not empty, found empty", t.Name())
+	}
+}
+
+type testWrapWriter struct {
+	http.ResponseWriter
+}
+
+func (tw *testWrapWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+func TestGetResponseWriter(t *testing.T) {
+	rw := newResponseWriter(httptest.NewRecorder(), "", "")
+	got, ok := getResponseWriter(&testWrapWriter{&testWrapWriter{rw}})
+	if !ok || got != rw {
+		t.Errorf("%s expected responseWriter found through Unwrap, returned %v", t.Name(), got)
+	}
+	if _, ok := getResponseWriter(httptest.NewRecorder()); ok {
+		t.Errorf("%s expected no responseWriter, found one", t.Name())
+	}
+}
+
 func TestF_ReqIDEmpty(t *testing.T) {
 	next := func(w http.ResponseWriter, r *http.Request) {}
 	var ps _router.Params
 	w := &httptest.ResponseRecorder{}
 	r, _ := http.NewRequest("GET", "/health-check", nil)
-	thisF := f(next)
+	thisF := f(next, nil)
 	thisF(w, r, ps)
 	if r.Header.Get("Request-Id") == "" {
 		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
@@ -75,7 +129,7 @@ func TestF_ReqIDEmpty_XReqIDNotEmpty(t *testing.T) {
 	w := &httptest.ResponseRecorder{}
 	r, _ := http.NewRequest("GET", "/health-check", nil)
 	r.Header.Set("X-Request-Id", testXRequestID)
-	thisF := f(next)
+	thisF := f(next, nil)
 	thisF(w, r, ps)
 	if r.Header.Get("Request-Id") == "" {
 		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
@@ -91,7 +145,7 @@ func TestF_WithPathParams(t *testing.T) {
 	})
 	w := &httptest.ResponseRecorder{}
 	r, _ := http.NewRequest("GET", "/health-check", nil)
-	thisF := f(next)
+	thisF := f(next, nil)
 	thisF(w, r, ps)
 	if r.Header.Get("Request-Id") == "" {
 		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
@@ -112,7 +166,7 @@ func TestRecoverPanic(t *testing.T) {
 
 func TestResponseHeader(t *testing.T) {
 	w := &httptest.ResponseRecorder{}
-	rw := &responseWriter{w, 200, "", ""}
+	rw := &responseWriter{ResponseWriter: w, statusCode: 200}
 	responseHeader(rw, 200)
 }
 
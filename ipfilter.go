@@ -0,0 +1,219 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IPFilterOpts options for IP filter middleware.
+// Client IP is resolved with ClientIP, so set Opts.TrustedProxies if server is behind proxies.
+type IPFilterOpts struct {
+	// Allow CIDRs or IPs allowed to access. If empty then all addresses not denied are allowed.
+	Allow []string
+
+	// Deny CIDRs or IPs denied to access. Deny takes precedence over Allow.
+	Deny []string
+
+	// File optional, path to rules file reloaded every ReloadInterval. Each line is either
+	// "allow <cidr>" or "deny <cidr>", empty lines and lines started with '#' are ignored.
+	// Rules in file replace Allow and Deny.
+	File string
+
+	// Source optional, callback returning allow and deny lists, called every ReloadInterval.
+	// Ignored if File is set.
+	Source func() (allow []string, deny []string, err error)
+
+	// ReloadInterval interval of reloading File or Source. If empty then 30 seconds is used.
+	ReloadInterval time.Duration
+
+	// DeniedBody response body for rejected requests. If empty then "Forbidden" is used.
+	DeniedBody []byte
+
+	// DeniedContentType content type of DeniedBody. If empty then "text/plain; charset=utf-8" is used.
+	DeniedContentType string
+}
+
+type ipRules struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// IPFilter allow or deny requests based on client IP. Its rules can be updated while server is running.
+type IPFilter struct {
+	server   *Server
+	opts     IPFilterOpts
+	rules    atomic.Value // *ipRules
+	mu       sync.Mutex   // guards modTime
+	modTime  time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// IPFilter create IP filter. Use its Middleware method as middleware, e.g. s.Group("/admin", filter.Middleware).
+// If File or Source is set, rules are loaded immediately and reloaded periodically until Stop is called.
+// @opts: can be nil, if nil then all addresses are allowed until rules are updated.
+func (s *Server) IPFilter(opts *IPFilterOpts) (*IPFilter, error) {
+	f := &IPFilter{
+		server: s,
+		stop:   make(chan struct{}),
+	}
+	if opts != nil {
+		f.opts = *opts
+	}
+	if f.opts.ReloadInterval <= 0 {
+		f.opts.ReloadInterval = 30 * time.Second
+	}
+	if len(f.opts.DeniedBody) == 0 {
+		f.opts.DeniedBody = []byte(http.StatusText(http.StatusForbidden))
+	}
+	if f.opts.DeniedContentType == "" {
+		f.opts.DeniedContentType = "text/plain; charset=utf-8"
+	}
+	if err := f.Update(f.opts.Allow, f.opts.Deny); err != nil {
+		return nil, err
+	}
+	if f.opts.File == "" && f.opts.Source == nil {
+		return f, nil
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	go f.watch()
+	return f, nil
+}
+
+// Update replace allow and deny lists.
+func (f *IPFilter) Update(allow []string, deny []string) error {
+	a, err := parseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+	d, err := parseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+	f.rules.Store(&ipRules{allow: a, deny: d})
+	return nil
+}
+
+// Reload load rules from File or Source. File is only read if it changed since last load.
+// Current rules are kept if loading failed.
+func (f *IPFilter) Reload() error {
+	if f.opts.File == "" {
+		if f.opts.Source == nil {
+			return nil
+		}
+		allow, deny, err := f.opts.Source()
+		if err != nil {
+			return err
+		}
+		return f.Update(allow, deny)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.opts.File)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	content, err := ioutil.ReadFile(f.opts.File)
+	if err != nil {
+		return err
+	}
+	allow, deny, err := parseIPRules(content)
+	if err != nil {
+		return fmt.Errorf("httpserver: invalid ip rules file %s: %v", f.opts.File, err)
+	}
+	if err := f.Update(allow, deny); err != nil {
+		return err
+	}
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// Stop stop periodic reloading.
+func (f *IPFilter) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stop)
+	})
+}
+
+func (f *IPFilter) watch() {
+	ticker := time.NewTicker(f.opts.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			if err := f.Reload(); err != nil {
+				f.server.logger.Printf("%s | httpserver | ip filter reload failed: %v\n", time.Now().Format(time.RFC3339), err)
+			}
+		}
+	}
+}
+
+// Allowed check whether ip passes the rules.
+func (f *IPFilter) Allowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	rules := f.rules.Load().(*ipRules)
+	if containsIP(rules.deny, parsed) {
+		return false
+	}
+	return len(rules.allow) == 0 || containsIP(rules.allow, parsed)
+}
+
+// Middleware reject requests whose client IP doesn't pass the rules with 403.
+func (f *IPFilter) Middleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+		if f.Allowed(ip) {
+			next(w, r)
+			return
+		}
+		f.server.logger.Printf("%s | httpserver | %s | %s | %s | %s | %s\n", time.Now().Format(time.RFC3339), "IP DENIED", r.Method, r.URL.Path, RequestID(r.Context()), ip)
+		w.Header().Set("Content-Type", f.opts.DeniedContentType)
+		Response(w, http.StatusForbidden, f.opts.DeniedBody)
+	}
+}
+
+func parseIPRules(content []byte) ([]string, []string, error) {
+	var allow, deny []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("line %d: expected '<allow|deny> <cidr>'", line)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return nil, nil, fmt.Errorf("line %d: unknown rule '%s'", line, fields[0])
+		}
+	}
+	return allow, deny, scanner.Err()
+}
@@ -0,0 +1,138 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIPFilter_Allowed(t *testing.T) {
+	srv := newServer()
+	f, err := srv.IPFilter(&IPFilterOpts{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32"},
+		Deny:  []string{"10.0.0.13"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"10.1.2.3":    true,
+		"10.0.0.13":   false,
+		"192.168.1.1": false,
+		"2001:db8::1": true,
+		"2001:db9::1": false,
+		"invalid":     false,
+	}
+	for ip, expected := range cases {
+		if f.Allowed(ip) != expected {
+			t.Errorf("%s expected %v for %s", t.Name(), expected, ip)
+		}
+	}
+
+	if err := f.Update(nil, []string{"192.168.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Allowed("10.0.0.13") || f.Allowed("192.168.1.1") {
+		t.Errorf("%s expected updated rules applied", t.Name())
+	}
+	if err := f.Update([]string{"bad"}, nil); err == nil {
+		t.Errorf("%s expected error on invalid cidr", t.Name())
+	}
+}
+
+func TestIPFilter_NilOpts(t *testing.T) {
+	f, err := newServer().IPFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Allowed("192.168.1.1") {
+		t.Errorf("%s expected all addresses allowed without rules", t.Name())
+	}
+	if err := f.Update(nil, []string{"192.168.0.0/16"}); err != nil || f.Allowed("192.168.1.1") {
+		t.Errorf("%s expected updated rules applied, returned %v", t.Name(), err)
+	}
+}
+
+func TestIPFilter_Middleware(t *testing.T) {
+	srv := newServer()
+	f, _ := srv.IPFilter(&IPFilterOpts{Allow: []string{"127.0.0.1"}, DeniedBody: []byte(`{"error":"forbidden"}`), DeniedContentType: "application/json"})
+	h := f.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	w := httptest.NewRecorder()
+	h(newResponseWriter(w, "", ""), r)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.RemoteAddr = "8.8.8.8:1234"
+	w = httptest.NewRecorder()
+	h(newResponseWriter(w, "", ""), r)
+	if w.Code != http.StatusForbidden || w.Body.String() != `{"error":"forbidden"}` || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("%s expected configured 403, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+}
+
+func TestIPFilter_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules")
+	ioutil.WriteFile(path, []byte("# office\nallow 10.0.0.0/8\ndeny 10.0.0.1\n"), 0600)
+
+	srv := newServer()
+	f, err := srv.IPFilter(&IPFilterOpts{File: path, ReloadInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+	if !f.Allowed("10.0.0.2") || f.Allowed("10.0.0.1") || f.Allowed("8.8.8.8") {
+		t.Errorf("%s expected rules loaded from file", t.Name())
+	}
+
+	ioutil.WriteFile(path, []byte("allow 8.8.8.8\n"), 0600)
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if err := f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Allowed("8.8.8.8") || f.Allowed("10.0.0.2") {
+		t.Errorf("%s expected rules reloaded from file", t.Name())
+	}
+
+	ioutil.WriteFile(path, []byte("permit 8.8.8.8\n"), 0600)
+	os.Chtimes(path, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute))
+	if err := f.Reload(); err == nil {
+		t.Errorf("%s expected error on invalid rules file", t.Name())
+	}
+	if !f.Allowed("8.8.8.8") {
+		t.Errorf("%s expected previous rules kept", t.Name())
+	}
+}
+
+func TestIPFilter_Source(t *testing.T) {
+	srv := newServer()
+	deny := []string{"1.1.1.1"}
+	f, err := srv.IPFilter(&IPFilterOpts{Source: func() ([]string, []string, error) { return nil, deny, nil }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+	if f.Allowed("1.1.1.1") {
+		t.Errorf("%s expected 1.1.1.1 denied", t.Name())
+	}
+	deny = []string{"2.2.2.2"}
+	f.Reload()
+	if !f.Allowed("1.1.1.1") || f.Allowed("2.2.2.2") {
+		t.Errorf("%s expected rules reloaded from source", t.Name())
+	}
+}
@@ -0,0 +1,134 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimitOpts options for concurrency limiter.
+type ConcurrencyLimitOpts struct {
+	// MaxInFlight maximum number of requests handled at the same time. Must be greater than 0.
+	MaxInFlight int
+
+	// MaxQueue maximum number of requests waiting for a free slot. If empty then requests are rejected right away when saturated.
+	MaxQueue int
+
+	// QueueTimeout maximum time a request waits in queue. If empty then 1 second is used.
+	QueueTimeout time.Duration
+
+	// RetryAfter value of Retry-After header sent on rejection. If empty then 1 second is used.
+	RetryAfter time.Duration
+
+	// Rejected optional, triggered if request is shed. If empty then default 503 response is used.
+	Rejected http.HandlerFunc
+}
+
+// ConcurrencyLimiter cap number of in-flight requests, shedding load with 503 once saturated.
+// Use its Middleware method globally with Server.Use or per route.
+type ConcurrencyLimiter struct {
+	opts       ConcurrencyLimitOpts
+	slots      chan struct{}
+	queue      chan struct{}
+	inFlight   int64
+	queued     int64
+	rejected   uint64
+	retryAfter string
+}
+
+// NewConcurrencyLimiter create concurrency limiter.
+func NewConcurrencyLimiter(opts *ConcurrencyLimitOpts) *ConcurrencyLimiter {
+	if opts.MaxInFlight <= 0 {
+		panic("httpserver: ConcurrencyLimitOpts.MaxInFlight must be greater than 0")
+	}
+	l := &ConcurrencyLimiter{
+		opts:  *opts,
+		slots: make(chan struct{}, opts.MaxInFlight),
+	}
+	if l.opts.MaxQueue > 0 {
+		l.queue = make(chan struct{}, l.opts.MaxQueue)
+	}
+	if l.opts.QueueTimeout <= 0 {
+		l.opts.QueueTimeout = time.Second
+	}
+	if l.opts.RetryAfter <= 0 {
+		l.opts.RetryAfter = time.Second
+	}
+	l.retryAfter = strconv.Itoa(int((l.opts.RetryAfter + time.Second - 1) / time.Second))
+	return l
+}
+
+// InFlight number of requests currently handled.
+func (l *ConcurrencyLimiter) InFlight() int64 {
+	return atomic.LoadInt64(&l.inFlight)
+}
+
+// Queued number of requests currently waiting for a free slot.
+func (l *ConcurrencyLimiter) Queued() int64 {
+	return atomic.LoadInt64(&l.queued)
+}
+
+// Rejected total number of requests shed since limiter is created.
+func (l *ConcurrencyLimiter) Rejected() uint64 {
+	return atomic.LoadUint64(&l.rejected)
+}
+
+// Middleware limit concurrency of the next handler.
+func (l *ConcurrencyLimiter) Middleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !l.acquire(r) {
+			if r.Context().Err() != nil {
+				// client is gone, nobody to respond to.
+				return
+			}
+			atomic.AddUint64(&l.rejected, 1)
+			w.Header().Set("Retry-After", l.retryAfter)
+			if l.opts.Rejected != nil {
+				l.opts.Rejected(w, r)
+				return
+			}
+			ResponseString(w, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+			return
+		}
+		atomic.AddInt64(&l.inFlight, 1)
+		defer func() {
+			atomic.AddInt64(&l.inFlight, -1)
+			<-l.slots
+		}()
+		next(w, r)
+	}
+}
+
+// acquire take a free slot, waiting in queue up to QueueTimeout if there is room in it.
+func (l *ConcurrencyLimiter) acquire(r *http.Request) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queue == nil {
+		return false
+	}
+	select {
+	case l.queue <- struct{}{}:
+	default:
+		return false
+	}
+	atomic.AddInt64(&l.queued, 1)
+	defer func() {
+		atomic.AddInt64(&l.queued, -1)
+		<-l.queue
+	}()
+
+	timer := time.NewTimer(l.opts.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
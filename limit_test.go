@@ -0,0 +1,106 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l := NewConcurrencyLimiter(&ConcurrencyLimitOpts{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: 50 * time.Millisecond, RetryAfter: 2 * time.Second})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := l.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		ResponseString(w, http.StatusOK, "ok")
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(newResponseWriter(w, "", ""), httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	var first, queued *httptest.ResponseRecorder
+	go func() {
+		defer wg.Done()
+		first = serve()
+	}()
+	<-started
+	if l.InFlight() != 1 {
+		t.Errorf("%s expected 1 in-flight, returned %d", t.Name(), l.InFlight())
+	}
+
+	go func() {
+		defer wg.Done()
+		queued = serve()
+	}()
+	for l.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// queue is full, rejected right away.
+	rejected := serve()
+	if rejected.Code != http.StatusServiceUnavailable || rejected.Header().Get("Retry-After") != "2" {
+		t.Errorf("%s expected 503 with Retry-After, returned %d %q", t.Name(), rejected.Code, rejected.Header().Get("Retry-After"))
+	}
+
+	// queued request times out while first is still running.
+	for l.Queued() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	release <- struct{}{}
+	wg.Wait()
+	if first.Code != http.StatusOK {
+		t.Errorf("%s expected first request %d, returned %d", t.Name(), http.StatusOK, first.Code)
+	}
+	if queued.Code != http.StatusServiceUnavailable {
+		t.Errorf("%s expected queued request timed out, returned %d", t.Name(), queued.Code)
+	}
+	if l.Rejected() != 2 || l.InFlight() != 0 {
+		t.Errorf("%s expected 2 rejected and 0 in-flight, returned %d and %d", t.Name(), l.Rejected(), l.InFlight())
+	}
+}
+
+func TestConcurrencyLimiter_Queue(t *testing.T) {
+	l := NewConcurrencyLimiter(&ConcurrencyLimitOpts{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: time.Second})
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	h := l.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+	<-started
+	for l.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	wg.Wait()
+	if l.Rejected() != 0 {
+		t.Errorf("%s expected queued request served, %d rejected", t.Name(), l.Rejected())
+	}
+}
+
+func TestNewConcurrencyLimiter_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s expected panic", t.Name())
+		}
+	}()
+	NewConcurrencyLimiter(&ConcurrencyLimitOpts{})
+}
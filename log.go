@@ -32,13 +32,17 @@ func (s *Server) log(next http.HandlerFunc, params ...interface{}) http.HandlerF
 		start := time.Now()
 		next(w, r)
 		elapsed := time.Since(start)
-		var statusCode int
-		rw, ok := w.(*responseWriter)
+		var (
+			statusCode int
+			written    int64
+		)
+		rw, ok := getResponseWriter(w)
 		if !ok { // impossible...!!! but let be safe.
 			statusCode = http.StatusOK // default http.ResponseWriter status code
 		} else {
 			statusCode = rw.statusCode
+			written = rw.written
 		}
-		s.logger.Printf("%s | httpserver | %s | %d | %s | %v | %s\n", time.Now().Format(time.RFC3339), r.Method, statusCode, r.URL.Path, elapsed, r.Header.Get("Request-Id"))
+		s.logger.Printf("%s | httpserver | %s | %d | %s | %v | %s | %s | %dB\n", time.Now().Format(time.RFC3339), r.Method, statusCode, r.URL.Path, elapsed, RequestID(r.Context()), ClientIP(r), written)
 	}
 }
@@ -0,0 +1,199 @@
+package httpserver
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var defaultMaintenanceBody = []byte(`{"error":"service is under maintenance"}`)
+
+// MaintenanceOpts options for maintenance mode.
+type MaintenanceOpts struct {
+	// RetryAfter value of Retry-After header. If empty then 60 seconds is used.
+	RetryAfter time.Duration
+
+	// AllowPaths paths still served during maintenance, e.g. "/health".
+	// Path ending with '*' matches its prefix, e.g. "/admin/*".
+	AllowPaths []string
+
+	// JSONBody response body sent during maintenance. If empty then default error message is used.
+	JSONBody []byte
+
+	// HTMLBody optional, sent instead of JSONBody to clients accepting text/html, e.g. browsers.
+	HTMLBody []byte
+}
+
+// maintenance runtime state of maintenance mode.
+type maintenance struct {
+	enabled int32
+	mu      sync.RWMutex
+	opts    MaintenanceOpts
+	// exempt paths of endpoints registered by MaintenanceEndpoint and ReadinessEndpoint.
+	exempt map[string]struct{}
+}
+
+// SetMaintenance switch maintenance mode on or off at runtime.
+// While on, all routes except allowed paths respond 503 with Retry-After.
+// @opts: can be nil, if nil then options of the previous call are kept.
+func (s *Server) SetMaintenance(on bool, opts *MaintenanceOpts) {
+	m := &s.maintenance
+	if opts != nil {
+		m.mu.Lock()
+		m.opts = *opts
+		m.mu.Unlock()
+	}
+	var v int32
+	if on {
+		v = 1
+	}
+	if atomic.SwapInt32(&m.enabled, v) != v {
+		state := "off"
+		if on {
+			state = "on"
+		}
+		s.logger.Printf("%s | httpserver | maintenance mode is %s", time.Now().Format(time.RFC3339), state)
+	}
+}
+
+// InMaintenance whether server is in maintenance mode.
+func (s *Server) InMaintenance() bool {
+	return atomic.LoadInt32(&s.maintenance.enabled) == 1
+}
+
+// MaintenanceSignal toggle maintenance mode every time server process receives one of sigs, e.g. syscall.SIGUSR1.
+// Return function to stop listening to sigs.
+// @opts: can be nil, if nil then options of the previous call are kept.
+func (s *Server) MaintenanceSignal(opts *MaintenanceOpts, sigs ...os.Signal) (stop func()) {
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	go func() {
+		for {
+			select {
+			case <-c:
+				s.SetMaintenance(!s.InMaintenance(), opts)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// MaintenanceEndpoint register admin endpoint controlling maintenance mode, served even during maintenance.
+// GET respond current state, POST with form or query value enabled=true|false switch it.
+// @guard: required, middleware protecting the endpoint, e.g. BasicAuth or APIKey.
+func (s *Server) MaintenanceEndpoint(path string, guard Middleware) {
+	if guard == nil {
+		panic("httpserver: MaintenanceEndpoint requires guard middleware")
+	}
+	s.exemptMaintenance(path)
+	s.GET(path, s.maintenanceState, guard)
+	s.POST(path, func(w http.ResponseWriter, r *http.Request) {
+		on, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			ResponseJSON(w, http.StatusBadRequest, map[string]string{"error": "enabled must be true or false"})
+			return
+		}
+		s.SetMaintenance(on, nil)
+		s.maintenanceState(w, r)
+	}, guard)
+}
+
+func (s *Server) maintenanceState(w http.ResponseWriter, r *http.Request) {
+	ResponseJSON(w, http.StatusOK, map[string]bool{"maintenance": s.InMaintenance()})
+}
+
+// ReadinessEndpoint register readiness probe, served even during maintenance.
+// Respond 200 if server is ready, or 503 with Retry-After if it is in maintenance mode.
+func (s *Server) ReadinessEndpoint(path string, middlewares ...Middleware) {
+	s.exemptMaintenance(path)
+	s.GET(path, func(w http.ResponseWriter, r *http.Request) {
+		if s.InMaintenance() {
+			w.Header().Set("Retry-After", s.maintenanceRetryAfter())
+			ResponseJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "maintenance"})
+			return
+		}
+		ResponseJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	}, middlewares...)
+}
+
+func (s *Server) exemptMaintenance(path string) {
+	m := &s.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.exempt == nil {
+		m.exempt = make(map[string]struct{})
+	}
+	m.exempt[path] = struct{}{}
+}
+
+func (s *Server) maintenanceRetryAfter() string {
+	s.maintenance.mu.RLock()
+	retryAfter := s.maintenance.opts.RetryAfter
+	s.maintenance.mu.RUnlock()
+	if retryAfter <= 0 {
+		retryAfter = time.Minute
+	}
+	return strconv.Itoa(int((retryAfter + time.Second - 1) / time.Second))
+}
+
+// checkMaintenance respond 503 to requests of non allowed paths while in maintenance mode.
+func (s *Server) checkMaintenance(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.InMaintenance() {
+			next(w, r)
+			return
+		}
+		m := &s.maintenance
+		m.mu.RLock()
+		opts := m.opts
+		_, exempt := m.exempt[r.URL.Path]
+		m.mu.RUnlock()
+		if exempt || maintenanceAllowed(opts.AllowPaths, r.URL.Path) {
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", s.maintenanceRetryAfter())
+		w.Header().Set("Cache-Control", "no-store")
+		if len(opts.HTMLBody) > 0 && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			Response(w, http.StatusServiceUnavailable, opts.HTMLBody)
+			return
+		}
+		body := opts.JSONBody
+		if len(body) == 0 {
+			body = defaultMaintenanceBody
+		}
+		w.Header().Set("Content-Type", "application/json")
+		Response(w, http.StatusServiceUnavailable, body)
+	}
+}
+
+func maintenanceAllowed(allowPaths []string, path string) bool {
+	for _, p := range allowPaths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, p[:len(p)-1]) {
+				return true
+			}
+			continue
+		}
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
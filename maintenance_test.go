@@ -0,0 +1,100 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetMaintenance(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/api", testHandler)
+	srv.GET("/health", testHandler)
+	srv.GET("/static/app.js", testHandler)
+	srv.SetMaintenance(true, &MaintenanceOpts{
+		RetryAfter: 2 * time.Minute,
+		AllowPaths: []string{"/health", "/static/*"},
+		HTMLBody:   []byte("<h1>maintenance</h1>"),
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api", nil)
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusServiceUnavailable, w.Code)
+	}
+	if w.Header().Get("Retry-After") != "120" {
+		t.Errorf("%s expected Retry-After 120, returned %s", t.Name(), w.Header().Get("Retry-After"))
+	}
+	if w.Body.String() != string(defaultMaintenanceBody) {
+		t.Errorf("%s expected %s, returned %s", t.Name(), defaultMaintenanceBody, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	srv.handlers.ServeHTTP(w, r)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || w.Body.String() != "<h1>maintenance</h1>" {
+		t.Errorf("%s expected html body, returned %s", t.Name(), w.Body.String())
+	}
+
+	for _, path := range []string{"/health", "/static/app.js"} {
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest("GET", path, nil)
+		srv.handlers.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s expected %s allowed, returned %d", t.Name(), path, w.Code)
+		}
+	}
+
+	srv.SetMaintenance(false, nil)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api", nil)
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+}
+
+func TestMaintenanceEndpoint(t *testing.T) {
+	srv := New(&Opts{})
+	srv.MaintenanceEndpoint("/admin/maintenance", APIKey(&APIKeyOpts{Keys: map[string]string{"secret": "admin"}}))
+	srv.ReadinessEndpoint("/ready")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/admin/maintenance?enabled=true", nil)
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || srv.InMaintenance() {
+		t.Errorf("%s expected unauthorized request rejected, returned %d", t.Name(), w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set("X-API-Key", "secret")
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !srv.InMaintenance() {
+		t.Errorf("%s expected maintenance switched on, returned %d", t.Name(), w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/ready", nil)
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "maintenance") {
+		t.Errorf("%s expected readiness %d, returned %d %s", t.Name(), http.StatusServiceUnavailable, w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/admin/maintenance?enabled=false", nil)
+	r.Header.Set("X-API-Key", "secret")
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || srv.InMaintenance() {
+		t.Errorf("%s expected maintenance switched off through admin endpoint, returned %d", t.Name(), w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/ready", nil)
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected readiness %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+}
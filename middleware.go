@@ -1,66 +1,166 @@
 package httpserver
 
 import (
+	"fmt"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
+// LoggerMiddleware name of the logger middleware enabled by Opts.EnableLogger or WithLogger,
+// pass it to SkipMiddleware to exclude a route from access log.
+const LoggerMiddleware = "httpserver.logger"
+
+// RequestPredicate decide whether a middleware applies to a request.
+type RequestPredicate func(r *http.Request) bool
+
+// Use register middlewares applied to all routes, including the ones registered before.
+// Registering after server is started is reported as error in ListenError and ignored.
 func (s *Server) Use(m ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		s.reportError(fmt.Errorf("%w: middleware can't be added", ErrServerStarted))
+		return
+	}
 	for _, v := range m {
 		s.middlewares = append(s.middlewares, v)
 	}
 }
 
-// chainMiddlewares chain all middlewares to handler
-func (s *Server) chainMiddlewares(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
-	h := handler
-	var ch http.HandlerFunc
+// Named give middleware a name so it can be excluded from specific routes or groups with SkipMiddleware.
+func Named(name string, m Middleware) Middleware {
+	return (&namedMiddleware{name: name, m: m}).middleware
+}
 
-	if len(middlewares) > 0 {
-		lM := len(middlewares) - 1
-		for i := lM; i >= 0; i-- {
-			ch = middlewares[i](h)
-			h = ch
+// SkipMiddleware exclude named middlewares, registered globally or in group, from a route or group.
+// Pass it along with route or group middlewares, e.g. s.GET("/health", handler, SkipMiddleware(LoggerMiddleware, "auth")).
+func SkipMiddleware(names ...string) Middleware {
+	return (&skipMiddleware{names: names}).middleware
+}
+
+// When apply middleware only to requests matching predicate, e.g. s.Use(When(Not(PathPrefix("/static/")), auth)).
+func When(pred RequestPredicate, m Middleware) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		wrapped := m(next, params...)
+		return func(w http.ResponseWriter, r *http.Request) {
+			if pred(r) {
+				wrapped(w, r)
+				return
+			}
+			next(w, r)
 		}
 	}
+}
 
-	if len(s.middlewares) > 0 {
-		lS := len(s.middlewares) - 1
-		for i := lS; i >= 0; i-- {
-			ch = s.middlewares[i](h)
-			h = ch
+// PathPrefix predicate matching requests whose path starts with any of prefixes.
+func PathPrefix(prefixes ...string) RequestPredicate {
+	return func(r *http.Request) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(r.URL.Path, p) {
+				return true
+			}
 		}
+		return false
 	}
+}
 
-	return h
+// Not negate predicate.
+func Not(pred RequestPredicate) RequestPredicate {
+	return func(r *http.Request) bool {
+		return !pred(r)
+	}
 }
 
-func (g *Group) chainMiddlewares(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
-	h := handler
-	var ch http.HandlerFunc
+// middlewareProbe passed as middleware param to ask named and skip middlewares about themselves.
+type middlewareProbe struct {
+	name  string
+	skips []string
+}
+
+type namedMiddleware struct {
+	name string
+	m    Middleware
+}
 
-	if len(middlewares) > 0 {
-		lM := len(middlewares) - 1
-		for i := lM; i >= 0; i-- {
-			ch = middlewares[i](h)
-			h = ch
+func (n *namedMiddleware) middleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	for _, p := range params {
+		if probe, ok := p.(*middlewareProbe); ok {
+			probe.name = n.name
+			return next
 		}
 	}
+	return n.m(next, params...)
+}
 
-	if len(g.middlewares) > 0 {
-		lG := len(g.middlewares) - 1
-		for i := lG; i >= 0; i-- {
-			ch = g.middlewares[i](h)
-			h = ch
+type skipMiddleware struct {
+	names []string
+}
+
+func (sk *skipMiddleware) middleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	for _, p := range params {
+		if probe, ok := p.(*middlewareProbe); ok {
+			probe.skips = sk.names
 		}
 	}
+	return next
+}
+
+// every method value of the same method shares one code pointer, used to recognize named and skip middlewares
+// without calling arbitrary middlewares.
+var (
+	namedMiddlewarePtr = reflect.ValueOf((&namedMiddleware{}).middleware).Pointer()
+	skipMiddlewarePtr  = reflect.ValueOf((&skipMiddleware{}).middleware).Pointer()
+)
+
+func probeMiddleware(m Middleware) (probe *middlewareProbe, named bool, skip bool) {
+	ptr := reflect.ValueOf(m).Pointer()
+	if ptr != namedMiddlewarePtr && ptr != skipMiddlewarePtr {
+		return nil, false, false
+	}
+	probe = &middlewareProbe{}
+	m(nil, probe)
+	return probe, ptr == namedMiddlewarePtr, ptr == skipMiddlewarePtr
+}
+
+// chainMiddlewares chain all middlewares to handler
+func (s *Server) chainMiddlewares(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
+	return chain(handler, s.middlewares, middlewares)
+}
 
-	if len(g.server.middlewares) > 0 {
-		lS := len(g.server.middlewares) - 1
-		for i := lS; i >= 0; i-- {
-			ch = g.server.middlewares[i](h)
-			h = ch
+func (g *Group) chainMiddlewares(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
+	return chain(handler, g.server.middlewares, g.middlewares, middlewares)
+}
+
+// chain wrap handler with lists of middlewares, the first list is the outermost.
+// Named middlewares excluded by SkipMiddleware in any list are left out.
+func chain(handler http.HandlerFunc, lists ...[]Middleware) http.HandlerFunc {
+	skipped := make(map[string]struct{})
+	for _, l := range lists {
+		for _, m := range l {
+			if probe, _, skip := probeMiddleware(m); skip {
+				for _, name := range probe.skips {
+					skipped[name] = struct{}{}
+				}
+			}
 		}
 	}
 
+	h := handler
+	for i := len(lists) - 1; i >= 0; i-- {
+		for j := len(lists[i]) - 1; j >= 0; j-- {
+			m := lists[i][j]
+			probe, named, skip := probeMiddleware(m)
+			if skip {
+				continue
+			}
+			if named {
+				if _, ok := skipped[probe.name]; ok {
+					continue
+				}
+			}
+			h = m(h)
+		}
+	}
 	return h
 }
@@ -2,6 +2,8 @@ package httpserver
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -28,3 +30,64 @@ func TestGroupChainMiddlewares(t *testing.T) {
 	grp := srv.Group("/test", TestMiddleware)
 	grp.chainMiddlewares(handler, TestMiddleware)
 }
+
+func recordMiddleware(name string, calls *[]string) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name)
+			next(w, r)
+		}
+	}
+}
+
+func TestSkipMiddleware(t *testing.T) {
+	var calls []string
+	srv := New(&Opts{})
+	srv.Use(Named("auth", recordMiddleware("auth", &calls)), recordMiddleware("global", &calls))
+	grp := srv.Group("/admin", Named("audit", recordMiddleware("audit", &calls)))
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+
+	cases := []struct {
+		name     string
+		h        http.HandlerFunc
+		expected []string
+	}{
+		{"no skip", srv.chainMiddlewares(handler), []string{"auth", "global"}},
+		{"skip global", srv.chainMiddlewares(handler, SkipMiddleware("auth")), []string{"global"}},
+		{"group", grp.chainMiddlewares(handler), []string{"auth", "global", "audit"}},
+		{"skip group", grp.chainMiddlewares(handler, SkipMiddleware("audit", "auth")), []string{"global"}},
+		{"skip unknown", srv.chainMiddlewares(handler, SkipMiddleware("unknown")), []string{"auth", "global"}},
+	}
+	for _, c := range cases {
+		calls = nil
+		c.h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if !reflect.DeepEqual(calls, c.expected) {
+			t.Errorf("%s/%s expected %v, returned %v", t.Name(), c.name, c.expected, calls)
+		}
+	}
+}
+
+func TestSkipMiddleware_Logger(t *testing.T) {
+	srv := New(&Opts{EnableLogger: true})
+	probe, named, _ := probeMiddleware(srv.middlewares[0])
+	if !named || probe.name != LoggerMiddleware {
+		t.Fatalf("%s expected logger middleware named %s", t.Name(), LoggerMiddleware)
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	h := srv.chainMiddlewares(handler, SkipMiddleware(LoggerMiddleware))
+	if reflect.ValueOf(h).Pointer() != reflect.ValueOf(handler).Pointer() {
+		t.Errorf("%s expected logger excluded from chain", t.Name())
+	}
+}
+
+func TestWhen(t *testing.T) {
+	var calls []string
+	m := When(Not(PathPrefix("/health", "/static/")), recordMiddleware("auth", &calls))
+	h := m(func(w http.ResponseWriter, r *http.Request) {})
+	for _, path := range []string{"/health", "/static/app.js", "/users"} {
+		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if !reflect.DeepEqual(calls, []string{"auth"}) {
+		t.Errorf("%s expected middleware applied only to /users, returned %v", t.Name(), calls)
+	}
+}
@@ -0,0 +1,210 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNotAcceptable    = errors.New("httpserver: no acceptable response format")
+	ErrTemplateNotFound = errors.New("httpserver: template not found")
+)
+
+// Encoder write v as response body with status code, e.g. ResponseJSON.
+type Encoder func(w http.ResponseWriter, statusCode int, v interface{}) error
+
+// View data rendered with registered html template for clients accepting html, other formats encode only Data.
+type View struct {
+	// Template name of template registered with RegisterTemplate.
+	Template string
+
+	// Data passed to template, or encoded as is for non html formats.
+	Data interface{}
+}
+
+type encoderEntry struct {
+	mediaType string
+	encode    Encoder
+}
+
+type templateEntry struct {
+	tmpl    string
+	funcMap []template.FuncMap
+}
+
+// negotiator registry of response encoders, ordered by server preference, and html templates.
+type negotiator struct {
+	mu        sync.RWMutex
+	encoders  []encoderEntry
+	templates map[string]templateEntry
+}
+
+var defaultNegotiator = &negotiator{
+	encoders: []encoderEntry{
+		{"application/json", ResponseJSON},
+		{"application/xml", ResponseXML},
+		{"text/html", nil}, // rendered from View with registered template.
+		{"text/plain", responseText},
+		{"application/yaml", codecEncoder("application/yaml")},
+		{"text/csv", codecEncoder("text/csv")},
+		{"application/msgpack", codecEncoder("application/msgpack")},
+		{"application/cbor", codecEncoder("application/cbor")},
+	},
+	templates: make(map[string]templateEntry),
+}
+
+// RegisterEncoder add encoder of media type used by Respond, or replace existing one.
+// New media types have lower preference than existing ones when client accepts several equally.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	n := defaultNegotiator
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	mediaType = strings.ToLower(mediaType)
+	for i := range n.encoders {
+		if n.encoders[i].mediaType == mediaType {
+			n.encoders[i].encode = enc
+			return
+		}
+	}
+	n.encoders = append(n.encoders, encoderEntry{mediaType, enc})
+}
+
+func (n *negotiator) has(mediaType string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, e := range n.encoders {
+		if e.mediaType == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterTemplate add html template rendered by Respond for View with Template name.
+// @tmpl: template content, e.g. loaded with LoadTemplate.
+func RegisterTemplate(name string, tmpl string, funcMap ...template.FuncMap) {
+	n := defaultNegotiator
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.templates[name] = templateEntry{tmpl, funcMap}
+}
+
+// Respond write v in format picked from Accept request header, by its q-values then by server preference:
+// JSON, XML, HTML, plain text, then other registered codecs. HTML is only offered if v is View.
+// Respond 406 and return ErrNotAcceptable if no format is acceptable.
+// Call at the end line of your handler.
+func Respond(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) error {
+	w.Header().Add("Vary", "Accept")
+	n := defaultNegotiator
+	view, isView := v.(View)
+	if pv, ok := v.(*View); ok && pv != nil {
+		view, isView = *pv, true
+	}
+
+	n.mu.RLock()
+	candidates := make([]encoderEntry, 0, len(n.encoders))
+	for _, e := range n.encoders {
+		if e.mediaType == "text/html" && e.encode == nil && !isView {
+			continue
+		}
+		candidates = append(candidates, e)
+	}
+	n.mu.RUnlock()
+
+	e, ok := negotiateMediaType(r.Header.Get("Accept"), candidates)
+	if !ok {
+		types := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			types = append(types, c.mediaType)
+		}
+		ResponseString(w, http.StatusNotAcceptable, fmt.Sprintf("%s, available: %s", http.StatusText(http.StatusNotAcceptable), strings.Join(types, ", ")))
+		return ErrNotAcceptable
+	}
+	if e.encode == nil {
+		return n.render(w, statusCode, view)
+	}
+	if isView {
+		v = view.Data
+	}
+	return e.encode(w, statusCode, v)
+}
+
+func (n *negotiator) render(w http.ResponseWriter, statusCode int, view View) error {
+	n.mu.RLock()
+	t, ok := n.templates[view.Template]
+	n.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, view.Template)
+	}
+	html, err := RenderHTML(view.Template, t.tmpl, view.Data, requestFuncMap(w, t.funcMap)...)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ResponseString(w, statusCode, html)
+	return nil
+}
+
+func responseText(w http.ResponseWriter, statusCode int, v interface{}) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	ResponseString(w, statusCode, v)
+	return nil
+}
+
+// negotiateMediaType pick candidate with the highest q-value in Accept, the most specific range of Accept decides
+// q-value of a media type. Earlier candidates win ties. Empty Accept accepts the first candidate.
+func negotiateMediaType(accept string, candidates []encoderEntry) (encoderEntry, bool) {
+	if len(candidates) == 0 {
+		return encoderEntry{}, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return candidates[0], true
+	}
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []acceptRange
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, q := parseQValue(v)
+		if mediaType == "" {
+			continue
+		}
+		ranges = append(ranges, acceptRange{strings.ToLower(mediaType), q})
+	}
+
+	var (
+		best  encoderEntry
+		bestQ float64
+	)
+	for _, c := range candidates {
+		q, specificity := 0.0, -1
+		for _, ar := range ranges {
+			s := mediaRangeMatch(ar.mediaType, c.mediaType)
+			if s > specificity {
+				q, specificity = ar.q, s
+			}
+		}
+		if specificity >= 0 && q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// mediaRangeMatch return specificity of media range matching media type: 2 exact, 1 type/*, 0 */*, -1 no match.
+func mediaRangeMatch(mediaRange string, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*" || mediaRange == "*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1]):
+		return 1
+	}
+	return -1
+}
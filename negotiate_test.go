@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testNegotiateBody struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestRespond(t *testing.T) {
+	RegisterTemplate("testUser", `<p>{{ .Name }}</p>`)
+	body := View{Template: "testUser", Data: testNegotiateBody{Name: "john"}}
+	tests := []struct {
+		accept      string
+		v           interface{}
+		status      int
+		contentType string
+		body        string
+	}{
+		{"", body, http.StatusOK, "application/json", `{"name":"john"}`},
+		{"application/xml", body, http.StatusOK, "application/xml", "<testNegotiateBody><name>john</name></testNegotiateBody>"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", body, http.StatusOK, "text/html", "<p>john</p>"},
+		{"text/*;q=0.5, application/json;q=0.4", "plain", http.StatusOK, "text/plain", "plain"},
+		{"application/*;q=0.5, application/xml;q=0", body, http.StatusOK, "application/json", `{"name":"john"}`},
+		{"text/html", "not a view", http.StatusNotAcceptable, "", "Not Acceptable"},
+		{"image/png", body, http.StatusNotAcceptable, "", "Not Acceptable"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		Respond(w, r, http.StatusOK, tt.v)
+		if w.Code != tt.status {
+			t.Errorf("%s expected %d for %q, returned %d", t.Name(), tt.status, tt.accept, w.Code)
+		}
+		if tt.contentType != "" && !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) {
+			t.Errorf("%s expected %s for %q, returned %s", t.Name(), tt.contentType, tt.accept, w.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s expected body %s for %q, returned %s", t.Name(), tt.body, tt.accept, w.Body.String())
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s expected Vary Accept, returned %s", t.Name(), w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestRespond_RegisterEncoder(t *testing.T) {
+	RegisterEncoder("text/csv", func(w http.ResponseWriter, statusCode int, v interface{}) error {
+		w.Header().Set("Content-Type", "text/csv")
+		ResponseString(w, statusCode, "name\njohn")
+		return nil
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/csv")
+	Respond(w, r, http.StatusCreated, testNegotiateBody{Name: "john"})
+	if w.Code != http.StatusCreated || w.Body.String() != "name\njohn" {
+		t.Errorf("%s expected csv body, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,232 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	ProblemJSONMediaType = "application/problem+json"
+	ProblemXMLMediaType  = "application/problem+xml"
+
+	// problemXMLNamespace namespace of problem xml documents, see RFC 9457 appendix B.
+	problemXMLNamespace = "urn:ietf:rfc:7807"
+)
+
+// problemMediaTypes formats of ResponseProblem, plain json and xml are accepted as their problem variants.
+var problemMediaTypes = []encoderEntry{
+	{mediaType: ProblemJSONMediaType},
+	{mediaType: "application/json"},
+	{mediaType: ProblemXMLMediaType},
+	{mediaType: "application/xml"},
+	{mediaType: "text/xml"},
+}
+
+// Problem details of an error response, see RFC 9457.
+type Problem struct {
+	// Type URI reference identifying problem type. If empty then "about:blank" is meant,
+	// the problem has no semantics beyond its status code.
+	Type string
+
+	// Title short summary of problem type. If empty and Type is "about:blank" then status text is used.
+	Title string
+
+	// Status http status code. If empty then 500 is used.
+	Status int
+
+	// Detail explanation specific to this occurrence of the problem.
+	Detail string
+
+	// Instance URI reference identifying this occurrence. If empty then request id is used.
+	Instance string
+
+	// Extensions additional members, serialized alongside the standard ones, e.g. "balance" or "errors".
+	Extensions map[string]interface{}
+}
+
+// NewProblem create problem of status code with detail, title is status text.
+func NewProblem(statusCode int, detail string) *Problem {
+	return &Problem{Status: statusCode, Title: http.StatusText(statusCode), Detail: detail}
+}
+
+// With set extension member, standard members can't be overridden.
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("httpserver: problem %d", p.Status)
+	if p.Title != "" {
+		msg += ": " + p.Title
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	return msg
+}
+
+// members standard members followed by extensions sorted by name.
+func (p Problem) members() orderedMap {
+	m := orderedMap{}
+	standard := []mapItem{{"type", p.Type}, {"title", p.Title}, {"status", p.Status}, {"detail", p.Detail}, {"instance", p.Instance}}
+	for _, item := range standard {
+		if item.Value != "" && item.Value != 0 {
+			m = append(m, item)
+		}
+	}
+	keys := make([]string, 0, len(p.Extensions))
+	for k := range p.Extensions {
+		if !isProblemMember(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m = append(m, mapItem{k, p.Extensions[k]})
+	}
+	return m
+}
+
+func isProblemMember(name string) bool {
+	switch name {
+	case "type", "title", "status", "detail", "instance":
+		return true
+	}
+	return false
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.members())
+}
+
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for k, v := range members {
+		var err error
+		switch k {
+		case "type":
+			err = json.Unmarshal(v, &p.Type)
+		case "title":
+			err = json.Unmarshal(v, &p.Title)
+		case "status":
+			err = json.Unmarshal(v, &p.Status)
+		case "detail":
+			err = json.Unmarshal(v, &p.Detail)
+		case "instance":
+			err = json.Unmarshal(v, &p.Instance)
+		default:
+			var ext interface{}
+			err = json.Unmarshal(v, &ext)
+			p.With(k, ext)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalXML encode problem as <problem xmlns="urn:ietf:rfc:7807">, extension arrays items are <i> elements.
+func (p Problem) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Space: problemXMLNamespace, Local: "problem"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, item := range p.members() {
+		v, err := toGeneric(item.Value)
+		if err != nil {
+			return err
+		}
+		if err := encodeXMLMember(e, item.Key, v); err != nil {
+			return err
+		}
+	}
+	if err := e.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
+func encodeXMLMember(e *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case orderedMap:
+		for _, item := range t {
+			if err := encodeXMLMember(e, item.Key, item.Value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			if err := encodeXMLMember(e, "i", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := e.EncodeToken(xml.CharData(fmt.Sprint(t))); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// ResponseProblem response with problem details, as application/problem+xml if client prefers xml,
+// otherwise as application/problem+json. Missing status, title and instance are filled in, p is not modified.
+// Call at the end line of your handler.
+// @p: can be nil, if nil then 500 problem is used.
+func ResponseProblem(w http.ResponseWriter, r *http.Request, p *Problem) error {
+	var problem Problem
+	if p != nil {
+		problem = *p
+	}
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if problem.Title == "" && (problem.Type == "" || problem.Type == "about:blank") {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" && r != nil {
+		problem.Instance = RequestID(r.Context())
+	}
+	mediaType := ProblemJSONMediaType
+	if r != nil {
+		w.Header().Add("Vary", "Accept")
+		if e, ok := negotiateMediaType(r.Header.Get("Accept"), problemMediaTypes); ok && strings.HasSuffix(e.mediaType, "xml") {
+			mediaType = ProblemXMLMediaType
+		}
+	}
+	return ResponseCodec(w, problem.Status, mediaType, problem)
+}
+
+// problemHandler respond problem of status code, with request id as its instance.
+func (s *Server) problemHandler(statusCode int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f(func(w http.ResponseWriter, r *http.Request) {
+			ResponseProblem(w, r, NewProblem(statusCode, ""))
+		}, s.requestID)(w, r, nil)
+	})
+}
+
+// enableProblemDetails respond not found, method not allowed and panics with problem details.
+// Custom NotFoundHandler and PanicHandler take precedence.
+func (s *Server) enableProblemDetails() {
+	s.problemDetails = true
+	s.handlers.NotFound = s.problemHandler(http.StatusNotFound)
+	s.handlers.MethodNotAllowed = s.problemHandler(http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,97 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblem_JSON(t *testing.T) {
+	p := NewProblem(http.StatusForbidden, "balance is too low").With("balance", 30).With("status", 200)
+	p.Type = "https://example.com/probs/out-of-credit"
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	expected := `{"type":"https://example.com/probs/out-of-credit","title":"Forbidden","status":403,"detail":"balance is too low","balance":30}`
+	if string(b) != expected {
+		t.Errorf("%s expected %s, returned %s", t.Name(), expected, b)
+	}
+
+	var out Problem
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	if out.Status != http.StatusForbidden || out.Type != p.Type || out.Extensions["balance"] != float64(30) {
+		t.Errorf("%s expected decoded problem, returned %+v", t.Name(), out)
+	}
+}
+
+func TestProblem_XML(t *testing.T) {
+	p := NewProblem(http.StatusBadRequest, "").With("errors", []string{"a", "b"})
+	b, err := xml.Marshal(p)
+	if err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	expected := `<problem xmlns="urn:ietf:rfc:7807"><title>Bad Request</title><status>400</status><errors><i>a</i><i>b</i></errors></problem>`
+	if string(b) != expected {
+		t.Errorf("%s expected %s, returned %s", t.Name(), expected, b)
+	}
+}
+
+func TestResponseProblem(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", ProblemJSONMediaType, `{"title":"Not Found","status":404,"instance":"req-1"}`},
+		{"application/json", ProblemJSONMediaType, `"instance":"req-1"`},
+		{"application/xml", ProblemXMLMediaType, `<instance>req-1</instance>`},
+		{"text/html", ProblemJSONMediaType, `"status":404`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		r = r.WithContext(WithRequestID(r.Context(), "req-1"))
+		ResponseProblem(w, r, &Problem{Status: http.StatusNotFound})
+		if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s expected 404 %s for %q, returned %d %s", t.Name(), tt.contentType, tt.accept, w.Code, w.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s expected body %s for %q, returned %s", t.Name(), tt.body, tt.accept, w.Body.String())
+		}
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	srv := New(&Opts{ProblemDetails: true})
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	})
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/missing", http.StatusNotFound},
+		{"POST", "/panic", http.StatusMethodNotAllowed},
+		{"GET", "/panic", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(tt.method, tt.path, nil)
+		srv.handlers.ServeHTTP(w, r)
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s expected problem for %s %s, returned %s", t.Name(), tt.method, tt.path, w.Body.String())
+		}
+		if w.Code != tt.status || p.Status != tt.status || p.Instance == "" || p.Instance != w.Header().Get("Request-Id") {
+			t.Errorf("%s expected %d with request id instance for %s %s, returned %d %+v", t.Name(), tt.status, tt.method, tt.path, w.Code, p)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientKey struct{}
+
+// client real client address, scheme and host of a request resolved from trusted proxies headers.
+type client struct {
+	ip     string
+	scheme string
+	host   string
+}
+
+// parseTrustedProxies parse list of CIDRs or single IPs. Panic on invalid entry.
+func parseTrustedProxies(cidrs []string) []*net.IPNet {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		panic("httpserver: invalid trusted proxy: " + err.Error())
+	}
+	return nets
+}
+
+// parseCIDRs parse list of CIDRs or single IPs, single IP is treated as a network of its own.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, v := range cidrs {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address '%s'", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// containsIP check whether ip belongs to any of the networks.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	return containsIP(s.trustedProxies, parsed)
+}
+
+// resolveClient store real client address of the request into its context, available via ClientIP.
+func (s *Server) resolveClient(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(context.WithValue(r.Context(), clientKey{}, s.resolve(r))))
+	}
+}
+
+// resolve read forwarding headers only if the request comes from a trusted proxy.
+// Hops are walked from the nearest one, the first untrusted hop is the client.
+// Forwarded header takes precedence over X-Forwarded-For, which takes precedence over X-Real-IP.
+func (s *Server) resolve(r *http.Request) *client {
+	c := &client{
+		ip:     remoteIP(r.RemoteAddr),
+		scheme: "http",
+		host:   r.Host,
+	}
+	if r.TLS != nil {
+		c.scheme = "https"
+	}
+	if len(s.trustedProxies) == 0 || !s.trusted(c.ip) {
+		return c
+	}
+
+	if fwd := r.Header.Values("Forwarded"); len(fwd) > 0 {
+		elems := parseForwarded(strings.Join(fwd, ","))
+		for i := len(elems) - 1; i >= 0; i-- {
+			if elems[i]["for"] == "" {
+				continue
+			}
+			c.ip = elems[i]["for"]
+			if proto := elems[i]["proto"]; proto != "" {
+				c.scheme = strings.ToLower(proto)
+			}
+			if host := elems[i]["host"]; host != "" {
+				c.host = host
+			}
+			if !s.trusted(c.ip) {
+				break
+			}
+		}
+		return c
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := splitHeaderList(strings.Join(xff, ","))
+		for i := len(hops) - 1; i >= 0; i-- {
+			c.ip = remoteIP(hops[i])
+			if !s.trusted(c.ip) {
+				break
+			}
+		}
+	} else if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		c.ip = remoteIP(realIP)
+	}
+	// value appended by the nearest proxy is the one to be trusted.
+	if proto := splitHeaderList(strings.Join(r.Header.Values("X-Forwarded-Proto"), ",")); len(proto) > 0 {
+		c.scheme = strings.ToLower(proto[len(proto)-1])
+	}
+	if host := splitHeaderList(strings.Join(r.Header.Values("X-Forwarded-Host"), ",")); len(host) > 0 {
+		c.host = host[len(host)-1]
+	}
+	return c
+}
+
+// parseForwarded parse RFC 7239 Forwarded header into its elements of lowercased parameter name to value.
+func parseForwarded(header string) []map[string]string {
+	var elems []map[string]string
+	for _, e := range splitHeaderList(header) {
+		elem := make(map[string]string)
+		for _, pair := range strings.Split(e, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			v := strings.Trim(kv[1], `"`)
+			if strings.ToLower(kv[0]) == "for" {
+				v = remoteIP(v)
+			}
+			elem[strings.ToLower(kv[0])] = v
+		}
+		elems = append(elems, elem)
+	}
+	return elems
+}
+
+func splitHeaderList(header string) []string {
+	var list []string
+	for _, v := range strings.Split(header, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// remoteIP strip port and IPv6 brackets from address, e.g. "[::1]:8080" into "::1".
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+func getClient(r *http.Request) *client {
+	if c, ok := r.Context().Value(clientKey{}).(*client); ok {
+		return c
+	}
+	return nil
+}
+
+// ClientIP return real client IP of the request, resolved from forwarding headers set by trusted proxies.
+func ClientIP(r *http.Request) string {
+	if c := getClient(r); c != nil {
+		return c.ip
+	}
+	return remoteIP(r.RemoteAddr)
+}
+
+// ClientScheme return scheme, "http" or "https", of the request as seen by the client.
+func ClientScheme(r *http.Request) string {
+	if c := getClient(r); c != nil {
+		return c.scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// ClientHost return host of the request as seen by the client.
+func ClientHost(r *http.Request) string {
+	if c := getClient(r); c != nil {
+		return c.host
+	}
+	return r.Host
+}
@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	if len(nets) != 3 {
+		t.Fatalf("%s expected 3 networks, returned %d", t.Name(), len(nets))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s expected panic on invalid proxy", t.Name())
+		}
+	}()
+	parseTrustedProxies([]string{"invalid"})
+}
+
+func TestResolveClient(t *testing.T) {
+	srv := New(&Opts{TrustedProxies: []string{"10.0.0.0/8", "fd00::/8"}})
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		ip      string
+		scheme  string
+		host    string
+	}{
+		{"untrusted remote", "1.2.3.4:5000", map[string]string{"X-Forwarded-For": "9.9.9.9"}, "1.2.3.4", "http", "example.com"},
+		{"xff", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "9.9.9.9, 5.5.5.5, 10.0.0.2", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com"}, "5.5.5.5", "https", "api.example.com"},
+		{"xff all trusted", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3", "http", "example.com"},
+		{"forwarded", "[fd00::1]:5000", map[string]string{"Forwarded": `for=192.0.2.60;proto=https;host=fwd.example.com, for="[fd00::2]:80"`}, "192.0.2.60", "https", "fwd.example.com"},
+		{"x-real-ip", "10.0.0.1:5000", map[string]string{"X-Real-IP": "7.7.7.7"}, "7.7.7.7", "http", "example.com"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		r.RemoteAddr = c.remote
+		for k, v := range c.headers {
+			r.Header.Set(k, v)
+		}
+		srv.resolveClient(func(w http.ResponseWriter, r *http.Request) {
+			if ClientIP(r) != c.ip {
+				t.Errorf("%s/%s expected ip %s, returned %s", t.Name(), c.name, c.ip, ClientIP(r))
+			}
+			if ClientScheme(r) != c.scheme {
+				t.Errorf("%s/%s expected scheme %s, returned %s", t.Name(), c.name, c.scheme, ClientScheme(r))
+			}
+			if ClientHost(r) != c.host {
+				t.Errorf("%s/%s expected host %s, returned %s", t.Name(), c.name, c.host, ClientHost(r))
+			}
+		})(httptest.NewRecorder(), r)
+	}
+}
+
+func TestClientIP_WithoutServer(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "[::1]:8080"
+	if ClientIP(r) != "::1" {
+		t.Errorf("%s expected ::1, returned %s", t.Name(), ClientIP(r))
+	}
+}
@@ -0,0 +1,216 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	_uuid "github.com/google/uuid"
+)
+
+const (
+	defaultRequestIDHeader    = "Request-Id"
+	defaultRequestIDMaxLength = 128
+	defaultRequestIDCharset   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:"
+)
+
+type requestIDKey struct{}
+
+// RequestIDOpts options for request id generation and propagation.
+type RequestIDOpts struct {
+	// Header request and response header carrying request id. If empty then "Request-Id" is used.
+	Header string
+
+	// Generator generate new request id. If empty then GenerateUUID is used.
+	// Built-in generators: GenerateUUID, GenerateULID, GenerateKSUID and SnowflakeGenerator.
+	Generator func() string
+
+	// TrustIncoming reuse request id sent by client in Header, or in X-Request-Id, if it is valid.
+	TrustIncoming bool
+
+	// MaxLength maximum length of incoming request id. If empty then 128 is used.
+	MaxLength int
+
+	// Charset characters allowed in incoming request id. If empty then alphanumeric, '-', '_', '.' and ':' are allowed.
+	Charset string
+}
+
+type requestIDConfig struct {
+	header    string
+	generate  func() string
+	trust     bool
+	maxLength int
+	allowed   [256]bool
+}
+
+// defaultRequestID trust incoming Request-Id or X-Request-Id, otherwise generate UUIDv4.
+var defaultRequestID = newRequestIDConfig(&RequestIDOpts{TrustIncoming: true})
+
+// newRequestIDConfig return nil if opts is nil, so default is used.
+func newRequestIDConfig(opts *RequestIDOpts) *requestIDConfig {
+	if opts == nil {
+		return nil
+	}
+	c := &requestIDConfig{
+		header:    opts.Header,
+		generate:  opts.Generator,
+		trust:     opts.TrustIncoming,
+		maxLength: opts.MaxLength,
+	}
+	if c.header == "" {
+		c.header = defaultRequestIDHeader
+	}
+	if c.generate == nil {
+		c.generate = GenerateUUID
+	}
+	if c.maxLength <= 0 {
+		c.maxLength = defaultRequestIDMaxLength
+	}
+	charset := opts.Charset
+	if charset == "" {
+		charset = defaultRequestIDCharset
+	}
+	for i := 0; i < len(charset); i++ {
+		c.allowed[charset[i]] = true
+	}
+	return c
+}
+
+func (c *requestIDConfig) valid(id string) bool {
+	if id == "" || len(id) > c.maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if !c.allowed[id[i]] {
+			return false
+		}
+	}
+	return true
+}
+
+// incoming return request id sent by client if trusted and valid, otherwise empty string.
+func (c *requestIDConfig) incoming(header func(string) string) string {
+	if !c.trust {
+		return ""
+	}
+	if id := header(c.header); c.valid(id) {
+		return id
+	}
+	if id := header("X-Request-Id"); c.valid(id) {
+		return id
+	}
+	return ""
+}
+
+// RequestID return request id of current request, to be propagated to downstream services and logs.
+// Return empty string if ctx doesn't come from request handled by this package.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID return copy of ctx carrying request id, e.g. for background jobs spawned by a handler.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// GenerateUUID generate random UUIDv4, e.g. "0b8e4c4e-8f5e-4a43-9a3d-3c7d1f0e2b6a".
+func GenerateUUID() string {
+	return _uuid.New().String()
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// GenerateULID generate lexicographically sortable ULID, e.g. "01ARZ3NDEKTSV4RRFFQ69G5FAV".
+func GenerateULID() string {
+	var id [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	rand.Read(id[6:])
+
+	// 128 bits encoded into 26 characters of 5 bits, the first one holds only 3 bits.
+	n := new(big.Int).SetBytes(id[:])
+	out := make([]byte, 26)
+	mask := big.NewInt(31)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 5)
+	}
+	return string(out)
+}
+
+const (
+	base62       = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	ksuidEpoch   = 1400000000
+	ksuidEncoded = 27
+)
+
+// GenerateKSUID generate K-Sortable Unique IDentifier, e.g. "0ujtsYcgvSTl8PAuAdqWYSMnLOv".
+func GenerateKSUID() string {
+	var id [20]byte
+	binary.BigEndian.PutUint32(id[:4], uint32(time.Now().Unix()-ksuidEpoch))
+	rand.Read(id[4:])
+
+	n := new(big.Int).SetBytes(id[:])
+	base := big.NewInt(62)
+	rem := new(big.Int)
+	out := make([]byte, ksuidEncoded)
+	for i := ksuidEncoded - 1; i >= 0; i-- {
+		n.DivMod(n, base, rem)
+		out[i] = base62[rem.Int64()]
+	}
+	return string(out)
+}
+
+const (
+	snowflakeEpoch    = 1288834974657 // Twitter epoch in milliseconds
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// SnowflakeGenerator create generator of 64 bits time ordered ids, e.g. "1541815603606036480".
+// @node: unique id of this instance, from 0 to 1023.
+func SnowflakeGenerator(node int64) func() string {
+	if node < 0 || node > snowflakeMaxNode {
+		panic("httpserver: snowflake node must be between 0 and " + strconv.Itoa(snowflakeMaxNode))
+	}
+	var (
+		mu   sync.Mutex
+		last int64
+		seq  int64
+	)
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		if now < last {
+			// clock moved backwards, stick to the last timestamp.
+			now = last
+		}
+		if now == last {
+			seq = (seq + 1) & snowflakeMaxSeq
+			if seq == 0 {
+				for now <= last {
+					time.Sleep(100 * time.Microsecond)
+					now = time.Now().UnixNano() / int64(time.Millisecond)
+				}
+			}
+		} else {
+			seq = 0
+		}
+		last = now
+		id := (now-snowflakeEpoch)<<(snowflakeNodeBits+snowflakeSeqBits) | node<<snowflakeSeqBits | seq
+		return strconv.FormatInt(id, 10)
+	}
+}
@@ -0,0 +1,109 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_router "github.com/julienschmidt/httprouter"
+)
+
+func serveRequestID(rid *requestIDConfig, r *http.Request) (string, *httptest.ResponseRecorder) {
+	var id string
+	w := httptest.NewRecorder()
+	f(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestID(r.Context())
+		ResponseString(w, http.StatusOK, "ok")
+	}, rid)(w, r, nil)
+	return id, w
+}
+
+func TestRequestID_Default(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	id, w := serveRequestID(nil, r)
+	if id == "" || w.Header().Get("Request-Id") != id {
+		t.Errorf("%s expected generated id in context and response, returned %q and %q", t.Name(), id, w.Header().Get("Request-Id"))
+	}
+	if _, ok := w.Header()["X-Request-Id"]; ok {
+		t.Errorf("%s expected no empty X-Request-Id header", t.Name())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "incoming-1")
+	id, w = serveRequestID(nil, r)
+	if id != "incoming-1" || w.Header().Get("X-Request-Id") != "incoming-1" {
+		t.Errorf("%s expected incoming id reused, returned %q", t.Name(), id)
+	}
+}
+
+func TestRequestID_Configured(t *testing.T) {
+	rid := newRequestIDConfig(&RequestIDOpts{
+		Header:        "X-Correlation-Id",
+		Generator:     func() string { return "generated" },
+		TrustIncoming: true,
+		MaxLength:     10,
+	})
+	cases := map[string]string{
+		"":              "generated",
+		"abc-123":       "abc-123",
+		"way-too-long-": "generated",
+		"bad id\n":      "generated",
+	}
+	for incoming, expected := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if incoming != "" {
+			r.Header.Set("X-Correlation-Id", incoming)
+		}
+		id, w := serveRequestID(rid, r)
+		if id != expected || w.Header().Get("X-Correlation-Id") != expected {
+			t.Errorf("%s expected %q for incoming %q, returned %q", t.Name(), expected, incoming, id)
+		}
+	}
+
+	untrusted := newRequestIDConfig(&RequestIDOpts{Generator: func() string { return "generated" }})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Request-Id", "spoofed")
+	if id, _ := serveRequestID(untrusted, r); id != "generated" {
+		t.Errorf("%s expected incoming id ignored, returned %q", t.Name(), id)
+	}
+}
+
+func TestRequestID_Generators(t *testing.T) {
+	ulid := GenerateULID()
+	if len(ulid) != 26 || strings.Trim(ulid, crockford) != "" {
+		t.Errorf("%s invalid ULID %q", t.Name(), ulid)
+	}
+	ksuid := GenerateKSUID()
+	if len(ksuid) != 27 || strings.Trim(ksuid, base62) != "" {
+		t.Errorf("%s invalid KSUID %q", t.Name(), ksuid)
+	}
+	if len(GenerateUUID()) != 36 {
+		t.Errorf("%s invalid UUID", t.Name())
+	}
+
+	gen := SnowflakeGenerator(1)
+	seen := make(map[string]struct{})
+	prev := ""
+	for i := 0; i < 10000; i++ {
+		id := gen()
+		if _, ok := seen[id]; ok {
+			t.Fatalf("%s duplicated snowflake id %s", t.Name(), id)
+		}
+		if len(id) == len(prev) && id <= prev {
+			t.Fatalf("%s expected increasing ids, %s after %s", t.Name(), id, prev)
+		}
+		seen[id] = struct{}{}
+		prev = id
+	}
+}
+
+func TestRequestID_Params(t *testing.T) {
+	ps := _router.Params{{Key: "id", Value: "1"}}
+	r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	f(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") != "1" || RequestID(r.Context()) == "" {
+			t.Errorf("%s expected path params and request id", t.Name())
+		}
+	}, nil)(httptest.NewRecorder(), r, ps)
+}
@@ -2,24 +2,14 @@ package httpserver
 
 import (
 	"bytes"
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"html/template"
 	"io/ioutil"
 	"net/http"
-	"time"
 )
 
+// responseHeader send header with status code. Date and request id headers are injected by responseWriter.
 func responseHeader(w http.ResponseWriter, statusCode int) {
-	w.Header().Set("Date", time.Now().Format(time.RFC1123))
-	rw, ok := w.(*responseWriter)
-	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Request-Id", rw.requestID)
-	w.Header().Set("X-Request-Id", rw.xRequestID)
 	w.WriteHeader(statusCode)
 }
 
@@ -30,14 +20,12 @@ func Response(w http.ResponseWriter, statusCode int, body []byte) {
 	w.Write(body)
 }
 
-// ResponseJSON response by writing body with json encoder into http.ResponseWriter.
+// ResponseJSON response by writing body with json codec, see RegisterCodec, into http.ResponseWriter.
 // Body must be either struct or map[string]interface{}. Otherwise would result in incorrect parsing at client side.
 // If you have []byte as response body, then use Response function instead.
 // Call at the end line of your handler.
 func ResponseJSON(w http.ResponseWriter, statusCode int, body interface{}) error {
-	w.Header().Set("Content-Type", "application/json")
-	responseHeader(w, statusCode)
-	return json.NewEncoder(w).Encode(body)
+	return ResponseCodec(w, statusCode, "application/json", body)
 }
 
 // ResponseString response in form of string whatever passed into body param.
@@ -47,14 +35,12 @@ func ResponseString(w http.ResponseWriter, statusCode int, body interface{}) {
 	fmt.Fprintf(w, "%v", body)
 }
 
-// ResponseXML response by writing body with xml encoder into http.ResponseWriter.
+// ResponseXML response by writing body with xml codec, see RegisterCodec, into http.ResponseWriter.
 // Body must be either struct or map[string]interface{}. Otherwise would result in incorrect parsing at client side.
 // If you have []byte as response body, then use Response function instead.
 // Call at the end line of your handler.
 func ResponseXML(w http.ResponseWriter, statusCode int, body interface{}) error {
-	w.Header().Set("Content-Type", "application/xml")
-	responseHeader(w, statusCode)
-	return xml.NewEncoder(w).Encode(body)
+	return ResponseCodec(w, statusCode, "application/xml", body)
 }
 
 // ResponseHTML render and return html with given data.
@@ -65,7 +51,7 @@ func ResponseXML(w http.ResponseWriter, statusCode int, body interface{}) error
 func ResponseHTML(w http.ResponseWriter, tmplName string, tmpl string, data interface{},
 	funcMap ...template.FuncMap) error {
 
-	html, err := RenderHTML(tmplName, tmpl, data, funcMap...)
+	html, err := RenderHTML(tmplName, tmpl, data, requestFuncMap(w, funcMap)...)
 	if err != nil {
 		return err
 	}
@@ -73,6 +59,15 @@ func ResponseHTML(w http.ResponseWriter, tmplName string, tmpl string, data inte
 	return nil
 }
 
+// requestFuncMap prepend per request template functions to funcMap, so the ones passed by caller take precedence.
+func requestFuncMap(w http.ResponseWriter, funcMap []template.FuncMap) []template.FuncMap {
+	rw, ok := getResponseWriter(w)
+	if !ok || len(rw.templateFuncs) == 0 {
+		return funcMap
+	}
+	return append([]template.FuncMap{rw.templateFuncs}, funcMap...)
+}
+
 // RenderHTML render template with given data into string.
 // @tmplName: template name if a template is wrapped inside {{ define "tmplName" }}, otherwise empty string.
 // @tmpl: template content in form of string loaded from template file.
@@ -103,7 +98,7 @@ func RenderHTML(tmplName string, tmpl string, data interface{}, funcMap ...templ
 }
 
 func ResponseMultiHTML(w http.ResponseWriter, mainTmplName string, tmplNameToTmpl map[string]string, data interface{}, funcMap ...template.FuncMap) error {
-	html, err := RenderMultiHTML(mainTmplName, tmplNameToTmpl, data, funcMap...)
+	html, err := RenderMultiHTML(mainTmplName, tmplNameToTmpl, data, requestFuncMap(w, funcMap)...)
 	if err != nil {
 		return err
 	}
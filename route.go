@@ -0,0 +1,82 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	_router "github.com/julienschmidt/httprouter"
+)
+
+// ErrServerStarted returned when routes or middlewares are registered after server is started.
+var ErrServerStarted = errors.New("httpserver: server already started")
+
+// route registered handler whose middlewares chain is built lazily, once server starts or on first request.
+type route struct {
+	server      *Server
+	group       *Group
+	handler     http.HandlerFunc
+	middlewares []Middleware
+	once        sync.Once
+	chained     http.HandlerFunc
+
+	// handle chained handler wrapped with server handling, e.g. request id, built along with chained.
+	handle _router.Handle
+}
+
+func (rt *route) build() {
+	rt.once.Do(func() {
+		s := rt.server
+		if rt.group != nil {
+			rt.chained = rt.group.chainMiddlewares(rt.handler, rt.middlewares...)
+		} else {
+			rt.chained = s.chainMiddlewares(rt.handler, rt.middlewares...)
+		}
+		rt.handle = f(s.resolveClient(s.checkMaintenance(s.recoverPanic(rt.chained))), s.requestID)
+	})
+}
+
+func (rt *route) serve(w http.ResponseWriter, r *http.Request, ps _router.Params) {
+	rt.build()
+	rt.handle(w, r, ps)
+}
+
+// handle register handler into router, its middlewares chain and request id handling are built when server starts.
+// @group: group the route belongs to, nil if registered directly to server.
+func (s *Server) handle(method string, path string, handler http.HandlerFunc, group *Group, middlewares []Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		s.reportError(fmt.Errorf("%w: route %s %s can't be registered", ErrServerStarted, method, path))
+		return
+	}
+	rt := &route{
+		server:      s,
+		group:       group,
+		handler:     handler,
+		middlewares: middlewares,
+	}
+	s.routes = append(s.routes, rt)
+	s.handlers.Handle(method, path, rt.serve)
+}
+
+// build mark server as started and build middlewares chain of all routes.
+func (s *Server) build() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+	for _, rt := range s.routes {
+		rt.build()
+	}
+}
+
+// reportError log error and pass it to ListenError if someone is listening, it is dropped otherwise.
+func (s *Server) reportError(err error) {
+	s.logger.Printf("%s | httpserver | %v", time.Now().Format(time.RFC3339), err)
+	select {
+	case s.errChan <- err:
+	default:
+	}
+}
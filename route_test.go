@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRoute_LateUse(t *testing.T) {
+	var calls []string
+	srv := New(&Opts{})
+	srv.GET("/users", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+	grp := srv.Group("/v1")
+	grp.GET("/users", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+	srv.Use(recordMiddleware("late", &calls))
+	srv.build()
+
+	for _, path := range []string{"/users", "/v1/users"} {
+		calls = nil
+		handle, ps, _ := srv.handlers.Lookup(http.MethodGet, path)
+		handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil), ps)
+		if len(calls) != 2 || calls[0] != "late" {
+			t.Errorf("%s expected middleware registered after route applied to %s, returned %v", t.Name(), path, calls)
+		}
+	}
+}
+
+func TestRoute_RegisterAfterStart(t *testing.T) {
+	srv := New(&Opts{})
+	srv.build()
+
+	srv.GET("/late", func(w http.ResponseWriter, r *http.Request) {})
+	if handle, _, _ := srv.handlers.Lookup(http.MethodGet, "/late"); handle != nil {
+		t.Errorf("%s expected route not registered after start", t.Name())
+	}
+	expectStartedError(t, srv)
+
+	srv.Use(TestMiddleware)
+	if len(srv.middlewares) != 0 {
+		t.Errorf("%s expected middleware not added after start", t.Name())
+	}
+	expectStartedError(t, srv)
+}
+
+func expectStartedError(t *testing.T, srv *Server) {
+	select {
+	case err := <-srv.ListenError():
+		if !errors.Is(err, ErrServerStarted) {
+			t.Errorf("%s expected ErrServerStarted, returned %v", t.Name(), err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("%s expected error reported", t.Name())
+	}
+}
+
+func TestRoute_LateRequestID(t *testing.T) {
+	sb := Build(8080).AddHandler(http.MethodGet, "/users", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	sb.WithRequestID(&RequestIDOpts{Header: "X-Correlation-Id", Generator: func() string { return "generated" }})
+	sb.srv.build()
+
+	w := httptest.NewRecorder()
+	handle, ps, _ := sb.srv.handlers.Lookup(http.MethodGet, "/users")
+	handle(w, httptest.NewRequest(http.MethodGet, "/users", nil), ps)
+	if w.Header().Get("X-Correlation-Id") != "generated" {
+		t.Errorf("%s expected request id configured after route applied, returned %v", t.Name(), w.Header())
+	}
+}
+
+func TestRoute_ReportErrorUnread(t *testing.T) {
+	srv := New(&Opts{})
+	srv.build()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 2*errChanBuffer; i++ {
+		srv.GET("/late", func(w http.ResponseWriter, r *http.Request) {})
+	}
+	if len(srv.ListenError()) != errChanBuffer || runtime.NumGoroutine() > before {
+		t.Errorf("%s expected %d buffered errors without goroutines left, returned %d errors and %d goroutines",
+			t.Name(), errChanBuffer, len(srv.ListenError()), runtime.NumGoroutine()-before)
+	}
+}
@@ -0,0 +1,146 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// CSPNoncePlaceholder placeholder in ContentSecurityPolicy replaced with per request nonce, e.g. "script-src 'nonce-{nonce}'".
+	CSPNoncePlaceholder = "{nonce}"
+
+	// CSPNonceTemplateFunc name of template function rendering CSP nonce in ResponseHTML and ResponseMultiHTML.
+	CSPNonceTemplateFunc = "cspNonce"
+)
+
+type cspNonceKey struct{}
+
+// SecureHeadersOpts options for security headers middleware. Empty fields are not sent.
+type SecureHeadersOpts struct {
+	// HSTSMaxAge Strict-Transport-Security max-age in seconds. Only sent if server has TLS enabled.
+	HSTSMaxAge int
+
+	// HSTSIncludeSubdomains add includeSubDomains directive to Strict-Transport-Security.
+	HSTSIncludeSubdomains bool
+
+	// HSTSPreload add preload directive to Strict-Transport-Security.
+	HSTSPreload bool
+
+	// ContentTypeNosniff send X-Content-Type-Options: nosniff.
+	ContentTypeNosniff bool
+
+	// FrameOptions X-Frame-Options value, e.g. "DENY" or "SAMEORIGIN".
+	FrameOptions string
+
+	// ReferrerPolicy Referrer-Policy value, e.g. "no-referrer".
+	ReferrerPolicy string
+
+	// PermissionsPolicy Permissions-Policy value, e.g. "camera=(), microphone=()".
+	PermissionsPolicy string
+
+	// ContentSecurityPolicy Content-Security-Policy value.
+	// Every CSPNoncePlaceholder in it is replaced with a fresh nonce on each request.
+	ContentSecurityPolicy string
+
+	// CSPReportOnly send policy as Content-Security-Policy-Report-Only instead.
+	CSPReportOnly bool
+}
+
+// SecureHeadersStrict preset suitable for html apps serving only their own resources.
+// Scripts must carry nonce, e.g. `<script nonce="{{ cspNonce }}">`.
+func SecureHeadersStrict() *SecureHeadersOpts {
+	return &SecureHeadersOpts{
+		HSTSMaxAge:            63072000,
+		HSTSIncludeSubdomains: true,
+		HSTSPreload:           true,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		PermissionsPolicy:     "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), microphone=(), payment=(), usb=()",
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-" + CSPNoncePlaceholder + "'; object-src 'none'; base-uri 'none'; frame-ancestors 'none'; form-action 'self'",
+	}
+}
+
+// SecureHeadersRelaxed preset suitable for apps embedding third party resources or being framed by themselves.
+func SecureHeadersRelaxed() *SecureHeadersOpts {
+	return &SecureHeadersOpts{
+		HSTSMaxAge:            15552000,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		ContentSecurityPolicy: "default-src 'self' https: data: 'unsafe-inline'; object-src 'none'; frame-ancestors 'self'",
+	}
+}
+
+// SecureHeaders middleware setting security related response headers.
+// @opts: can be nil, if nil then SecureHeadersStrict is used.
+func (s *Server) SecureHeaders(opts *SecureHeadersOpts) Middleware {
+	if opts == nil {
+		opts = SecureHeadersStrict()
+	}
+	o := *opts
+	var hsts string
+	if o.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", o.HSTSMaxAge)
+		if o.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if o.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if o.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	withNonce := strings.Contains(o.ContentSecurityPolicy, CSPNoncePlaceholder)
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			if hsts != "" && s.tls != nil {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			if o.ContentTypeNosniff {
+				h.Set("X-Content-Type-Options", "nosniff")
+			}
+			if o.FrameOptions != "" {
+				h.Set("X-Frame-Options", o.FrameOptions)
+			}
+			if o.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", o.ReferrerPolicy)
+			}
+			if o.PermissionsPolicy != "" {
+				h.Set("Permissions-Policy", o.PermissionsPolicy)
+			}
+			if !withNonce {
+				if o.ContentSecurityPolicy != "" {
+					h.Set(cspHeader, o.ContentSecurityPolicy)
+				}
+				next(w, r)
+				return
+			}
+
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				ResponseString(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			nonce := base64.RawURLEncoding.EncodeToString(b)
+			h.Set(cspHeader, strings.Replace(o.ContentSecurityPolicy, CSPNoncePlaceholder, nonce, -1))
+			addTemplateFunc(w, CSPNonceTemplateFunc, func() string { return nonce })
+			next(w, r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce)))
+		}
+	}
+}
+
+// CSPNonce return Content-Security-Policy nonce of current request.
+// Return empty string if SecureHeaders middleware is not used or its policy has no nonce.
+func CSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceKey{}).(string)
+	return nonce
+}
@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecureHeaders_Strict(t *testing.T) {
+	srv := newServer()
+	srv.tls = &tls.Config{}
+	var nonce string
+	h := srv.SecureHeaders(nil)(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r)
+		ResponseHTML(w, "page", `<script nonce="{{ cspNonce }}"></script>`, nil)
+	})
+	w := httptest.NewRecorder()
+	h(newResponseWriter(w, "", ""), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if nonce == "" {
+		t.Fatalf("%s expected nonce in context", t.Name())
+	}
+	if !strings.HasPrefix(w.Header().Get("Strict-Transport-Security"), "max-age=63072000") {
+		t.Errorf("%s expected HSTS header, returned %q", t.Name(), w.Header().Get("Strict-Transport-Security"))
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "'nonce-"+nonce+"'") {
+		t.Errorf("%s expected nonce in CSP, returned %q", t.Name(), csp)
+	}
+	if !strings.Contains(w.Body.String(), `nonce="`+nonce+`"`) {
+		t.Errorf("%s expected nonce rendered, returned %s", t.Name(), w.Body.String())
+	}
+	for _, header := range []string{"X-Content-Type-Options", "X-Frame-Options", "Referrer-Policy", "Permissions-Policy"} {
+		if w.Header().Get(header) == "" {
+			t.Errorf("%s expected %s header set", t.Name(), header)
+		}
+	}
+}
+
+func TestSecureHeaders_NoTLS(t *testing.T) {
+	srv := newServer()
+	h := srv.SecureHeaders(SecureHeadersRelaxed())(func(w http.ResponseWriter, r *http.Request) {
+		if CSPNonce(r) != "" {
+			t.Errorf("%s expected no nonce", t.Name())
+		}
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("%s expected no HSTS header without TLS", t.Name())
+	}
+	if w.Header().Get("X-Frame-Options") != "SAMEORIGIN" {
+		t.Errorf("%s expected SAMEORIGIN, returned %q", t.Name(), w.Header().Get("X-Frame-Options"))
+	}
+}
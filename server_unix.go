@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"syscall"
 
 	_grace "github.com/facebookgo/grace/gracehttp"
 )
@@ -20,10 +21,12 @@ func (s *Server) serve() error {
 	if s.notFoundHandler != nil {
 		s.handlers.NotFound = s.notFoundHandler
 	}
+	go s.watchShutdown(syscall.SIGINT, syscall.SIGTERM)
 	return _grace.Serve(&http.Server{
 		Addr:        fmt.Sprintf(":%d", s.port),
 		Handler:     handler,
 		IdleTimeout: s.idleTimeout,
 		TLSConfig:   tlsConfig,
+		BaseContext: s.baseContext,
 	})
 }
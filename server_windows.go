@@ -3,9 +3,11 @@
 package httpserver
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"os"
 )
 
 // graceful is not support in Windows. Using built-in package instead. This is for avoiding this package failed to run locally, rarely Windows used in server now.
@@ -24,10 +26,22 @@ func (s *Server) serve() error {
 		Handler:     handler,
 		IdleTimeout: s.idleTimeout,
 		TLSConfig:   tlsConfig,
+		BaseContext: s.baseContext,
 	}
+	// close Done first so streams and websockets end, then wait for remaining requests.
+	go func() {
+		s.watchShutdown(os.Interrupt)
+		srv.Shutdown(context.Background())
+	}()
 
+	var err error
 	if tlsConfig != nil {
-		return srv.ListenAndServeTLS("", "")
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
 	}
-	return srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
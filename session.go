@@ -0,0 +1,481 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionCookieName = "session"
+	defaultSessionMaxAge     = 24 * time.Hour
+	sessionIDLength          = 32
+	maxCookieSize            = 4096
+
+	// FlashesTemplateFunc name of template function returning, and clearing, flash messages in ResponseHTML and ResponseMultiHTML.
+	FlashesTemplateFunc = "flashes"
+
+	flashesKey = "_flashes"
+)
+
+var (
+	ErrSessionTooLarge = errors.New("httpserver: session is too large to be stored in cookie")
+	ErrInvalidSession  = errors.New("httpserver: invalid session cookie")
+)
+
+type sessionKey struct{}
+
+// Store persist session values. Values must be encodable with encoding/gob,
+// custom types have to be registered with gob.Register.
+type Store interface {
+	// Load return session id and values from cookie value sent by client.
+	// Return empty id if session doesn't exist or has expired.
+	Load(cookie string) (id string, values map[string]interface{}, err error)
+
+	// Save persist session values for maxAge, return cookie value to be sent to client.
+	Save(id string, values map[string]interface{}, maxAge time.Duration) (cookie string, err error)
+
+	// Delete remove session, e.g. when it is destroyed or its id is rotated.
+	Delete(id string) error
+}
+
+// SessionOpts options for session middleware.
+type SessionOpts struct {
+	// Store where sessions are persisted, e.g. NewCookieStore or NewMemoryStore. Required.
+	Store Store
+
+	// CookieName cookie storing the session. If empty then "session" is used.
+	CookieName string
+
+	// CookiePath if empty then "/" is used.
+	CookiePath string
+
+	// CookieDomain optional.
+	CookieDomain string
+
+	// MaxAge session lifetime, refreshed every time session is modified. If empty then 24 hours is used.
+	MaxAge time.Duration
+
+	// Secure set Secure flag on cookie. Always set if request comes through TLS.
+	Secure bool
+
+	// SameSite cookie SameSite attribute. If empty then Lax is used.
+	SameSite http.SameSite
+}
+
+// Session values of current client kept across requests.
+type Session struct {
+	mu        sync.Mutex
+	id        string
+	oldID     string
+	values    map[string]interface{}
+	dirty     bool
+	destroyed bool
+}
+
+// Sessions middleware loading session into request context, available via GetSession,
+// and saving it back with cookie once handler writes the response.
+// Flash messages are available in templates rendered by ResponseHTML and ResponseMultiHTML via `{{ flashes }}`.
+func (s *Server) Sessions(opts *SessionOpts) Middleware {
+	if opts == nil || opts.Store == nil {
+		panic("httpserver: SessionOpts.Store is required")
+	}
+	o := *opts
+	if o.CookieName == "" {
+		o.CookieName = defaultSessionCookieName
+	}
+	if o.CookiePath == "" {
+		o.CookiePath = "/"
+	}
+	if o.MaxAge <= 0 {
+		o.MaxAge = defaultSessionMaxAge
+	}
+	if o.SameSite == 0 {
+		o.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			sess := &Session{}
+			if c, err := r.Cookie(o.CookieName); err == nil {
+				id, values, err := o.Store.Load(c.Value)
+				if err != nil {
+					s.logger.Printf("%s | httpserver | session load failed: %v | %s", time.Now().Format(time.RFC3339), err, RequestID(r.Context()))
+				} else if id != "" {
+					sess.id = id
+					sess.values = values
+				}
+			}
+			if sess.values == nil {
+				sess.values = make(map[string]interface{})
+			}
+
+			var once sync.Once
+			commit := func() {
+				once.Do(func() {
+					if err := sess.commit(w, r, &o); err != nil {
+						s.logger.Printf("%s | httpserver | session save failed: %v | %s", time.Now().Format(time.RFC3339), err, RequestID(r.Context()))
+					}
+				})
+			}
+			w.Header().Add("Vary", "Cookie")
+			hooked := beforeWriteHeader(w, commit)
+			addTemplateFunc(w, FlashesTemplateFunc, sess.Flashes)
+			next(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, sess)))
+			if !hooked || !HeaderWritten(w) {
+				commit()
+			}
+		}
+	}
+}
+
+// GetSession return session of current request stored by Sessions middleware.
+func GetSession(r *http.Request) (*Session, bool) {
+	sess, ok := r.Context().Value(sessionKey{}).(*Session)
+	return sess, ok
+}
+
+// ID return session id, empty if session is new and not saved yet.
+func (sess *Session) ID() string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.id
+}
+
+// Get return value of key, nil if not exist.
+func (sess *Session) Get(key string) interface{} {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.values[key]
+}
+
+// GetString return string value of key, empty if not exist or not a string.
+func (sess *Session) GetString(key string) string {
+	v, _ := sess.Get(key).(string)
+	return v
+}
+
+// GetInt return int value of key, 0 if not exist or not an int.
+func (sess *Session) GetInt(key string) int {
+	v, _ := sess.Get(key).(int)
+	return v
+}
+
+// GetBool return bool value of key, false if not exist or not a bool.
+func (sess *Session) GetBool(key string) bool {
+	v, _ := sess.Get(key).(bool)
+	return v
+}
+
+// Set store value of key.
+func (sess *Session) Set(key string, value interface{}) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.values[key] = value
+	sess.dirty = true
+}
+
+// Delete remove key.
+func (sess *Session) Delete(key string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if _, ok := sess.values[key]; ok {
+		delete(sess.values, key)
+		sess.dirty = true
+	}
+}
+
+// RenewID give session a new id while keeping its values, call it on login or privilege change to prevent session fixation.
+func (sess *Session) RenewID() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.oldID == "" {
+		sess.oldID = sess.id
+	}
+	sess.id = ""
+	sess.dirty = true
+}
+
+// Destroy remove session from store and client, e.g. on logout.
+func (sess *Session) Destroy() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.values = make(map[string]interface{})
+	sess.destroyed = true
+}
+
+// AddFlash add message shown once on the next rendered page, e.g. after redirect.
+func (sess *Session) AddFlash(msg string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	flashes, _ := sess.values[flashesKey].([]string)
+	sess.values[flashesKey] = append(flashes, msg)
+	sess.dirty = true
+}
+
+// Flashes return flash messages and clear them.
+func (sess *Session) Flashes() []string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	flashes, ok := sess.values[flashesKey].([]string)
+	if !ok {
+		return nil
+	}
+	delete(sess.values, flashesKey)
+	sess.dirty = true
+	return flashes
+}
+
+func (sess *Session) commit(w http.ResponseWriter, r *http.Request, o *SessionOpts) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	cookie := &http.Cookie{
+		Name:     o.CookieName,
+		Path:     o.CookiePath,
+		Domain:   o.CookieDomain,
+		Secure:   o.Secure || r.TLS != nil,
+		HttpOnly: true,
+		SameSite: o.SameSite,
+	}
+	if sess.destroyed {
+		ids := []string{sess.id, sess.oldID}
+		sess.id, sess.oldID = "", ""
+		for _, id := range ids {
+			if id != "" {
+				if err := o.Store.Delete(id); err != nil {
+					return err
+				}
+			}
+		}
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+		return nil
+	}
+	// nothing to save, don't send cookie to clients without session.
+	if !sess.dirty || (sess.id == "" && sess.oldID == "" && len(sess.values) == 0) {
+		return nil
+	}
+	if sess.oldID != "" {
+		if err := o.Store.Delete(sess.oldID); err != nil {
+			return err
+		}
+		sess.oldID = ""
+	}
+	if sess.id == "" {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		sess.id = id
+	}
+	value, err := o.Store.Save(sess.id, sess.values, o.MaxAge)
+	if err != nil {
+		return err
+	}
+	sess.dirty = false
+	cookie.Value = value
+	cookie.MaxAge = int(o.MaxAge / time.Second)
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, sessionIDLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CookieStore store session values in the cookie itself, encrypted and authenticated with AES-GCM.
+type CookieStore struct {
+	aeads []cipher.AEAD
+}
+
+type cookieSession struct {
+	ID      string
+	Values  map[string]interface{}
+	Expires int64
+}
+
+// NewCookieStore create cookie store.
+// @keys: 16, 24 or 32 bytes AES keys. The first one encrypts new cookies, the rest only decrypt, to allow key rotation.
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("httpserver: cookie store requires at least one key")
+	}
+	cs := &CookieStore{}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		cs.aeads = append(cs.aeads, aead)
+	}
+	return cs, nil
+}
+
+// Load decrypt session from cookie value.
+func (cs *CookieStore) Load(cookie string) (string, map[string]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil {
+		return "", nil, ErrInvalidSession
+	}
+	for _, aead := range cs.aeads {
+		if len(b) < aead.NonceSize() {
+			continue
+		}
+		plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+		if err != nil {
+			continue
+		}
+		var data cookieSession
+		if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&data); err != nil {
+			return "", nil, err
+		}
+		if time.Now().Unix() > data.Expires {
+			return "", nil, nil
+		}
+		return data.ID, data.Values, nil
+	}
+	return "", nil, ErrInvalidSession
+}
+
+// Save encrypt session into cookie value.
+func (cs *CookieStore) Save(id string, values map[string]interface{}, maxAge time.Duration) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&cookieSession{
+		ID:      id,
+		Values:  values,
+		Expires: time.Now().Add(maxAge).Unix(),
+	}); err != nil {
+		return "", err
+	}
+	aead := cs.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+buf.Len()+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, buf.Bytes(), nil))
+	if len(value) > maxCookieSize {
+		return "", ErrSessionTooLarge
+	}
+	return value, nil
+}
+
+// Delete do nothing, cookie is removed from client by the middleware.
+func (cs *CookieStore) Delete(id string) error {
+	return nil
+}
+
+// MemoryStore store sessions in process memory, expired sessions are garbage collected periodically.
+// Sessions are lost on restart and not shared between instances.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*memorySession
+	done     chan struct{}
+	once     sync.Once
+}
+
+type memorySession struct {
+	values  map[string]interface{}
+	expires time.Time
+}
+
+// NewMemoryStore create in-memory store.
+// @gcInterval: interval of removing expired sessions. If empty then 1 minute is used.
+func NewMemoryStore(gcInterval time.Duration) *MemoryStore {
+	if gcInterval <= 0 {
+		gcInterval = time.Minute
+	}
+	ms := &MemoryStore{
+		sessions: make(map[string]*memorySession),
+		done:     make(chan struct{}),
+	}
+	go ms.gc(gcInterval)
+	return ms
+}
+
+// Load return session of id carried by cookie.
+func (ms *MemoryStore) Load(cookie string) (string, map[string]interface{}, error) {
+	ms.mu.RLock()
+	sess, ok := ms.sessions[cookie]
+	ms.mu.RUnlock()
+	if !ok || time.Now().After(sess.expires) {
+		return "", nil, nil
+	}
+	return cookie, copyValues(sess.values), nil
+}
+
+// Save store session, cookie carries only its id.
+func (ms *MemoryStore) Save(id string, values map[string]interface{}, maxAge time.Duration) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.sessions[id] = &memorySession{
+		values:  copyValues(values),
+		expires: time.Now().Add(maxAge),
+	}
+	return id, nil
+}
+
+// Delete remove session.
+func (ms *MemoryStore) Delete(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.sessions, id)
+	return nil
+}
+
+// Len number of stored sessions, including expired ones not yet garbage collected.
+func (ms *MemoryStore) Len() int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return len(ms.sessions)
+}
+
+// Stop garbage collection.
+func (ms *MemoryStore) Stop() {
+	ms.once.Do(func() {
+		close(ms.done)
+	})
+}
+
+func (ms *MemoryStore) gc(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			ms.mu.Lock()
+			for id, sess := range ms.sessions {
+				if now.After(sess.expires) {
+					delete(ms.sessions, id)
+				}
+			}
+			ms.mu.Unlock()
+		case <-ms.done:
+			return
+		}
+	}
+}
+
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,121 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sessionRequest(srv *Server, method string, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, path, nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	srv.handlers.ServeHTTP(w, r)
+	return w
+}
+
+func TestCookieStore(t *testing.T) {
+	cs, err := NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("%s expected null error, returned %v", t.Name(), err)
+	}
+	value, err := cs.Save("id", map[string]interface{}{"user": "john", "n": 1}, time.Minute)
+	if err != nil {
+		t.Fatalf("%s expected null error, returned %v", t.Name(), err)
+	}
+	id, values, err := cs.Load(value)
+	if err != nil || id != "id" || values["user"] != "john" || values["n"] != 1 {
+		t.Errorf("%s expected session loaded, returned %s %v %v", t.Name(), id, values, err)
+	}
+	if _, _, err := cs.Load(value[:len(value)-2] + "AA"); err != ErrInvalidSession {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrInvalidSession, err)
+	}
+
+	// rotated key still decrypts cookies issued with the old one.
+	rotated, _ := NewCookieStore([]byte("fedcba9876543210fedcba9876543210"), []byte("0123456789abcdef0123456789abcdef"))
+	if id, _, err := rotated.Load(value); err != nil || id != "id" {
+		t.Errorf("%s expected session loaded with old key, returned %s %v", t.Name(), id, err)
+	}
+	if _, err := NewCookieStore([]byte("short")); err == nil {
+		t.Errorf("%s expected error for invalid key, returned null", t.Name())
+	}
+}
+
+func TestSessions_MemoryStore(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	defer store.Stop()
+	srv := New(&Opts{})
+	sessions := srv.Sessions(&SessionOpts{Store: store})
+	srv.GET("/anonymous", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	}, sessions)
+	srv.POST("/login", func(w http.ResponseWriter, r *http.Request) {
+		sess, _ := GetSession(r)
+		sess.RenewID()
+		sess.Set("user", "john")
+		sess.AddFlash("welcome")
+		http.Redirect(w, r, "/home", http.StatusSeeOther)
+	}, sessions)
+	srv.GET("/home", func(w http.ResponseWriter, r *http.Request) {
+		ResponseHTML(w, "home", `{{ range flashes }}{{ . }}{{ end }}`, nil)
+	}, sessions)
+	srv.POST("/logout", func(w http.ResponseWriter, r *http.Request) {
+		sess, _ := GetSession(r)
+		sess.Destroy()
+	}, sessions)
+
+	w := sessionRequest(srv, "GET", "/anonymous", nil)
+	if len(w.Result().Cookies()) != 0 {
+		t.Errorf("%s expected no cookie for anonymous client, returned %v", t.Name(), w.Result().Cookies())
+	}
+
+	w = sessionRequest(srv, "POST", "/login", nil)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("%s expected session cookie, returned %v", t.Name(), cookies)
+	}
+	firstID := cookies[0].Value
+
+	w = sessionRequest(srv, "GET", "/home", cookies)
+	if !strings.Contains(w.Body.String(), "welcome") {
+		t.Errorf("%s expected flash rendered, returned %s", t.Name(), w.Body.String())
+	}
+	w = sessionRequest(srv, "GET", "/home", cookies)
+	if strings.Contains(w.Body.String(), "welcome") {
+		t.Errorf("%s expected flash shown once, returned %s", t.Name(), w.Body.String())
+	}
+
+	w = sessionRequest(srv, "POST", "/login", cookies)
+	rotated := w.Result().Cookies()
+	if len(rotated) != 1 || rotated[0].Value == firstID {
+		t.Errorf("%s expected session id rotated on login, returned %v", t.Name(), rotated)
+	}
+	if id, _, _ := store.Load(firstID); id != "" {
+		t.Errorf("%s expected old session removed, found %s", t.Name(), id)
+	}
+
+	w = sessionRequest(srv, "POST", "/logout", rotated)
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge != -1 {
+		t.Errorf("%s expected session cookie removed, returned %v", t.Name(), c)
+	}
+	if store.Len() != 0 {
+		t.Errorf("%s expected empty store, returned %d sessions", t.Name(), store.Len())
+	}
+}
+
+func TestSession_Typed(t *testing.T) {
+	sess := &Session{values: make(map[string]interface{})}
+	sess.Set("name", "john")
+	sess.Set("age", 30)
+	sess.Set("admin", true)
+	if sess.GetString("name") != "john" || sess.GetInt("age") != 30 || !sess.GetBool("admin") {
+		t.Errorf("%s expected typed values, returned %v", t.Name(), sess.values)
+	}
+	if sess.GetInt("name") != 0 || sess.GetString("missing") != "" {
+		t.Errorf("%s expected zero values for mismatched types", t.Name())
+	}
+}
@@ -0,0 +1,506 @@
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSSEHeartbeat = 15 * time.Second
+	defaultHubBuffer    = 16
+)
+
+var (
+	ErrStreamingUnsupported = errors.New("httpserver: response writer does not support flushing")
+	ErrStreamClosed         = errors.New("httpserver: stream is closed")
+)
+
+// SSEOpts options of server-sent events stream.
+type SSEOpts struct {
+	// Heartbeat interval of comments keeping idle connection open through proxies. If empty then 15s is used.
+	Heartbeat time.Duration
+
+	// Retry reconnection delay advised to client. If empty then client default is used.
+	Retry time.Duration
+}
+
+// Stream server-sent events stream, see https://html.spec.whatwg.org/multipage/server-sent-events.html.
+// It ends when client disconnects, server shuts down or Close is called, see Done.
+type Stream struct {
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	lastEventID string
+
+	// mu guards writes to w, shared by Send and heartbeat.
+	mu     sync.Mutex
+	closed bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// SSE start server-sent events stream with default options.
+// Stream must be closed before handler returns, typically with defer.
+func SSE(w http.ResponseWriter, r *http.Request) (*Stream, error) {
+	return SSEWith(w, r, nil)
+}
+
+// SSEWith start server-sent events stream, sending its header right away.
+// Return ErrStreamingUnsupported if w can't be flushed.
+// @opts: can be nil, if nil then default is used.
+func SSEWith(w http.ResponseWriter, r *http.Request, opts *SSEOpts) (*Stream, error) {
+	var o SSEOpts
+	if opts != nil {
+		o = *opts
+	}
+	if o.Heartbeat <= 0 {
+		o.Heartbeat = defaultSSEHeartbeat
+	}
+	fl, ok := flusherOf(w)
+	if !ok {
+		return nil, ErrStreamingUnsupported
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+
+	s := &Stream{
+		w:           w,
+		flusher:     fl,
+		lastEventID: r.Header.Get("Last-Event-ID"),
+		done:        make(chan struct{}),
+	}
+	if o.Retry > 0 {
+		s.write(fmt.Sprintf("retry: %d\n\n", o.Retry.Milliseconds()))
+	} else {
+		fl.Flush()
+	}
+
+	s.wg.Add(1)
+	go s.watch(r, o.Heartbeat)
+	return s, nil
+}
+
+// flusherOf return flusher of w, looking through writers wrapping it with Unwrap.
+func flusherOf(w http.ResponseWriter) (http.Flusher, bool) {
+	for w != nil {
+		if fl, ok := w.(http.Flusher); ok {
+			return fl, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return nil, false
+}
+
+// watch send heartbeats until client disconnects, server shuts down or stream is closed.
+func (s *Stream) watch(r *http.Request, heartbeat time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.write(": heartbeat\n\n") != nil {
+				return
+			}
+		case <-r.Context().Done():
+			s.end()
+			return
+		case <-serverDone(r.Context()):
+			s.end()
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// LastEventID return id of the last event client received before reconnecting, empty on first connection.
+func (s *Stream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done return channel closed when stream ends.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send write event. Data of string or []byte is sent as is, other values as json.
+// @event: event type dispatched by client, if empty then "message".
+// @id: event id, client sends it back as Last-Event-ID when reconnecting. If empty then not sent.
+func (s *Stream) Send(event string, id string, data interface{}) error {
+	var payload []byte
+	switch t := data.(type) {
+	case string:
+		payload = []byte(t)
+	case []byte:
+		payload = t
+	default:
+		var buf bytes.Buffer
+		c, _ := LookupCodec("application/json")
+		if err := c.Encode(&buf, data); err != nil {
+			return err
+		}
+		payload = bytes.TrimRight(buf.Bytes(), "\n")
+	}
+
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: ")
+		b.WriteString(sseField(id))
+		b.WriteByte('\n')
+	}
+	if event != "" {
+		b.WriteString("event: ")
+		b.WriteString(sseField(event))
+		b.WriteByte('\n')
+	}
+	lines := strings.Split(strings.ReplaceAll(string(payload), "\r\n", "\n"), "\n")
+	for _, line := range lines {
+		b.WriteString("data: ")
+		b.WriteString(strings.TrimSuffix(line, "\r"))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return s.write(b.String())
+}
+
+// Comment write comment, ignored by client.
+func (s *Stream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(": ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return s.write(b.String())
+}
+
+// Close end stream and wait for its heartbeat to stop, nothing is written after it returns.
+func (s *Stream) Close() {
+	s.end()
+	s.wg.Wait()
+}
+
+func (s *Stream) end() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.done)
+	})
+}
+
+func (s *Stream) write(msg string) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrStreamClosed
+	}
+	_, err := s.w.Write([]byte(msg))
+	if err == nil {
+		s.flusher.Flush()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.end()
+	}
+	return err
+}
+
+// sseField remove line breaks which would end a field, and NUL which makes client ignore an id.
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "", "\x00", "").Replace(s)
+}
+
+// Event event published to Hub.
+type Event struct {
+	// ID event id. If empty then hub assigns a sequence number.
+	ID string
+
+	// Event event type. If empty then "message".
+	Event string
+
+	// Data sent as is if string or []byte, otherwise as json.
+	Data interface{}
+}
+
+// HubOpts options of broadcast hub.
+type HubOpts struct {
+	// Buffer number of events queued per subscriber. If empty then 16 is used.
+	Buffer int
+
+	// PublishTimeout how long Publish waits for a subscriber whose queue is full, before disconnecting it.
+	// Disconnected clients reconnect and resume from Replay. If empty then slow subscribers are disconnected right away.
+	PublishTimeout time.Duration
+
+	// Replay number of recent events kept per topic, sent to reconnecting clients after their Last-Event-ID.
+	// If empty then events are not replayed.
+	Replay int
+}
+
+type hubEntry struct {
+	seq uint64
+	ev  Event
+}
+
+// Hub topic based broadcaster of events to many subscribers, e.g. SSE streams served with Serve.
+type Hub struct {
+	opts HubOpts
+
+	// publishMu serializes publishing, so every subscriber receives events in order.
+	publishMu sync.Mutex
+
+	mu      sync.Mutex
+	seq     uint64
+	topics  map[string]map[*Subscription]struct{}
+	history map[string][]hubEntry
+	closed  bool
+}
+
+// NewHub create broadcast hub.
+// @opts: can be nil, if nil then default is used.
+func NewHub(opts *HubOpts) *Hub {
+	h := &Hub{
+		topics:  make(map[string]map[*Subscription]struct{}),
+		history: make(map[string][]hubEntry),
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Buffer <= 0 {
+		h.opts.Buffer = defaultHubBuffer
+	}
+	return h
+}
+
+// Subscription subscriber of hub topics.
+type Subscription struct {
+	hub    *Hub
+	topics []string
+	events chan Event
+
+	// mu guards sending to events against closing it.
+	mu     sync.Mutex
+	closed bool
+}
+
+// Events return channel of published events, closed when subscription is closed, by Close, by the hub closing,
+// or because subscriber was too slow.
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Close unsubscribe from hub.
+func (sub *Subscription) Close() {
+	h := sub.hub
+	h.mu.Lock()
+	for _, topic := range sub.topics {
+		delete(h.topics[topic], sub)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	h.mu.Unlock()
+	sub.close()
+}
+
+func (sub *Subscription) close() {
+	sub.mu.Lock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.events)
+	}
+	sub.mu.Unlock()
+}
+
+// deliver queue event, waiting up to timeout if queue is full. Return false if subscriber is too slow.
+func (sub *Subscription) deliver(ev Event, timeout time.Duration) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return true
+	}
+	select {
+	case sub.events <- ev:
+		return true
+	default:
+	}
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case sub.events <- ev:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Subscribe subscribe to topics. Events published after lastEventID which are still kept for replay are queued first.
+// @lastEventID: id of the last event received, e.g. Stream.LastEventID. If empty then nothing is replayed.
+func (h *Hub) Subscribe(lastEventID string, topics ...string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	replay := h.replay(lastEventID, topics)
+	sub := &Subscription{
+		hub:    h,
+		topics: topics,
+		events: make(chan Event, h.opts.Buffer+len(replay)),
+	}
+	for _, e := range replay {
+		sub.events <- e.ev
+	}
+	if h.closed {
+		sub.closed = true
+		close(sub.events)
+		return sub
+	}
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*Subscription]struct{})
+		}
+		h.topics[topic][sub] = struct{}{}
+	}
+	return sub
+}
+
+// replay return events of topics published after lastEventID in order, none if lastEventID is no longer kept.
+func (h *Hub) replay(lastEventID string, topics []string) []hubEntry {
+	if lastEventID == "" {
+		return nil
+	}
+	var (
+		after uint64
+		found bool
+	)
+	for _, topic := range topics {
+		for _, e := range h.history[topic] {
+			if e.ev.ID == lastEventID {
+				after, found = e.seq, true
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	var entries []hubEntry
+	for _, topic := range topics {
+		for _, e := range h.history[topic] {
+			if e.seq > after {
+				entries = append(entries, e)
+			}
+		}
+	}
+	// merge topics by publish order.
+	for i := 1; i < len(entries); i++ {
+		for j := i; j > 0 && entries[j].seq < entries[j-1].seq; j-- {
+			entries[j], entries[j-1] = entries[j-1], entries[j]
+		}
+	}
+	return entries
+}
+
+// Publish send event to subscribers of topic. Return the event id.
+// Subscribers which can't keep up are disconnected, see HubOpts.PublishTimeout.
+func (h *Hub) Publish(topic string, ev Event) string {
+	h.publishMu.Lock()
+	defer h.publishMu.Unlock()
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ""
+	}
+	h.seq++
+	if ev.ID == "" {
+		ev.ID = strconv.FormatUint(h.seq, 10)
+	}
+	if h.opts.Replay > 0 {
+		hist := append(h.history[topic], hubEntry{h.seq, ev})
+		if len(hist) > h.opts.Replay {
+			hist = append([]hubEntry(nil), hist[len(hist)-h.opts.Replay:]...)
+		}
+		h.history[topic] = hist
+	}
+	subs := make([]*Subscription, 0, len(h.topics[topic]))
+	for sub := range h.topics[topic] {
+		subs = append(subs, sub)
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		if !sub.deliver(ev, h.opts.PublishTimeout) {
+			sub.Close()
+		}
+	}
+	return ev.ID
+}
+
+// Subscribers return number of subscribers of topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.topics[topic])
+}
+
+// Close close all subscriptions, events published afterwards are dropped.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscription
+	for _, set := range h.topics {
+		for sub := range set {
+			subs = append(subs, sub)
+		}
+	}
+	h.topics = make(map[string]map[*Subscription]struct{})
+	h.mu.Unlock()
+	for _, sub := range subs {
+		sub.close()
+	}
+}
+
+// Serve stream events of topics to client as SSE until client disconnects, server shuts down or subscription ends.
+// Reconnecting clients resume after their Last-Event-ID. Call at the end line of your handler.
+// @opts: can be nil, if nil then default is used.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, opts *SSEOpts, topics ...string) error {
+	stream, err := SSEWith(w, r, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	sub := h.Subscribe(stream.LastEventID(), topics...)
+	defer sub.Close()
+	for {
+		select {
+		case <-stream.Done():
+			return nil
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(ev.Event, ev.ID, ev.Data); err != nil {
+				return err
+			}
+		}
+	}
+}
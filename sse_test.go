@@ -0,0 +1,149 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Last-Event-ID", "7")
+	s, err := SSEWith(w, r, &SSEOpts{Retry: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	if s.LastEventID() != "7" {
+		t.Errorf("%s expected last event id 7, returned %s", t.Name(), s.LastEventID())
+	}
+	s.Send("update", "8\n", "line1\nline2")
+	s.Send("", "", map[string]int{"n": 1})
+	s.Close()
+	if err := s.Send("", "", "late"); err != ErrStreamClosed {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrStreamClosed, err)
+	}
+
+	if w.Header().Get("Content-Type") != "text/event-stream; charset=utf-8" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("%s expected event stream headers, returned %v", t.Name(), w.Header())
+	}
+	expected := "retry: 3000\n\nid: 8\nevent: update\ndata: line1\ndata: line2\n\ndata: {\"n\":1}\n\n"
+	if w.Body.String() != expected {
+		t.Errorf("%s expected %q, returned %q", t.Name(), expected, w.Body.String())
+	}
+}
+
+func TestSSE_End(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	s, _ := SSEWith(w, r, &SSEOpts{Heartbeat: 5 * time.Millisecond})
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("%s expected stream to end on client disconnect", t.Name())
+	}
+	s.Close()
+	if !strings.Contains(w.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("%s expected heartbeat, returned %q", t.Name(), w.Body.String())
+	}
+
+	srv := New(&Opts{})
+	r = httptest.NewRequest("GET", "/events", nil).WithContext(srv.baseContext(nil))
+	s, _ = SSE(httptest.NewRecorder(), r)
+	srv.shutdown.close()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("%s expected stream to end on server shutdown", t.Name())
+	}
+	s.Close()
+}
+
+func TestSSE_Unsupported(t *testing.T) {
+	w := struct{ http.ResponseWriter }{httptest.NewRecorder()}
+	if _, err := SSE(w, httptest.NewRequest("GET", "/", nil)); err != ErrStreamingUnsupported {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrStreamingUnsupported, err)
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := NewHub(&HubOpts{Buffer: 2, Replay: 10})
+	a := h.Subscribe("", "news")
+	both := h.Subscribe("", "news", "sport")
+	h.Publish("news", Event{Data: "n1"})
+	h.Publish("sport", Event{Data: "s1"})
+	if h.Subscribers("news") != 2 || h.Subscribers("sport") != 1 {
+		t.Errorf("%s expected 2 and 1 subscribers, returned %d and %d", t.Name(), h.Subscribers("news"), h.Subscribers("sport"))
+	}
+	if ev := <-a.Events(); ev.ID != "1" || ev.Data != "n1" {
+		t.Errorf("%s expected event 1, returned %+v", t.Name(), ev)
+	}
+	if ev1, ev2 := <-both.Events(), <-both.Events(); ev1.ID != "1" || ev2.ID != "2" {
+		t.Errorf("%s expected events 1 and 2, returned %+v %+v", t.Name(), ev1, ev2)
+	}
+
+	// a is too slow: its queue of 2 is full on the third event.
+	h.Publish("news", Event{Data: "n2"})
+	h.Publish("news", Event{Data: "n3"})
+	h.Publish("news", Event{Data: "n4"})
+	<-a.Events()
+	<-a.Events()
+	if _, ok := <-a.Events(); ok {
+		t.Errorf("%s expected slow subscriber to be disconnected", t.Name())
+	}
+
+	resumed := h.Subscribe("2", "news", "sport")
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, (<-resumed.Events()).ID)
+	}
+	if strings.Join(ids, ",") != "3,4,5" {
+		t.Errorf("%s expected replay of 3,4,5, returned %v", t.Name(), ids)
+	}
+
+	h.Close()
+	if _, ok := <-resumed.Events(); ok {
+		t.Errorf("%s expected subscription closed with hub", t.Name())
+	}
+}
+
+func TestHub_Serve(t *testing.T) {
+	h := NewHub(&HubOpts{Replay: 10})
+	h.Publish("news", Event{Event: "headline", Data: "old"})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Serve(w, r, nil, "news")
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	defer resp.Body.Close()
+	for h.Subscribers("news") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	h.Publish("news", Event{Event: "headline", Data: map[string]string{"title": "new"}})
+
+	sc := bufio.NewScanner(resp.Body)
+	var lines []string
+	for len(lines) < 3 && sc.Scan() {
+		if sc.Text() != "" {
+			lines = append(lines, sc.Text())
+		}
+	}
+	expected := `id: 2|event: headline|data: {"title":"new"}`
+	if strings.Join(lines, "|") != expected {
+		t.Errorf("%s expected %s, returned %s", t.Name(), expected, strings.Join(lines, "|"))
+	}
+	h.Close()
+}
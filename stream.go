@@ -0,0 +1,231 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+const (
+	NDJSONMediaType = "application/x-ndjson"
+
+	defaultStreamFlushInterval = time.Second
+	defaultStreamFlushItems    = 100
+)
+
+var ErrNotChannel = errors.New("httpserver: iterator source is not a receivable channel")
+
+// Iterator return next item of a stream, ok is false once there are no more items.
+// ctx is the request context, blocking iterators should give up once it is done.
+// Streaming responses call it from a separate goroutine, one call at a time, so buffered items can be flushed meanwhile.
+type Iterator func(ctx context.Context) (item interface{}, ok bool, err error)
+
+// ChanIterator iterate items received from ch until it is closed, ch must be a channel of any element type.
+func ChanIterator(ch interface{}) Iterator {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.RecvDir == 0 {
+		return func(ctx context.Context) (interface{}, bool, error) {
+			return nil, false, ErrNotChannel
+		}
+	}
+	return func(ctx context.Context) (interface{}, bool, error) {
+		chosen, item, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: v},
+		})
+		if chosen == 0 {
+			return nil, false, ctx.Err()
+		}
+		if !ok {
+			return nil, false, nil
+		}
+		return item.Interface(), true, nil
+	}
+}
+
+// StreamOpts options of streaming responses, buffered items are flushed once either limit is reached.
+type StreamOpts struct {
+	// FlushInterval maximum time items are kept buffered. If empty then 1s is used.
+	FlushInterval time.Duration
+
+	// FlushItems maximum number of items kept buffered. If empty then 100 is used.
+	FlushItems int
+}
+
+// iterResult result of one Iterator call.
+type iterResult struct {
+	item interface{}
+	ok   bool
+	err  error
+}
+
+// StreamResult outcome of streaming response.
+type StreamResult struct {
+	// Items number of items written.
+	Items int
+
+	// Completed whether iterator was exhausted and response is well-formed.
+	// False if client disconnected or iterator or writing failed, see returned error.
+	Completed bool
+}
+
+// ResponseNDJSON stream items as newline delimited json, one item encoded with json codec per line.
+// Header is sent along with first item once it is available, so if iterator fails right away the caller can still respond with error.
+// Stop early when client disconnects, returning request context error.
+// Call at the end line of your handler.
+// @opts: can be nil, if nil then default is used.
+func ResponseNDJSON(w http.ResponseWriter, r *http.Request, statusCode int, next Iterator, opts *StreamOpts) (StreamResult, error) {
+	s := newJSONStream(w, r, opts)
+	return s.run(statusCode, NDJSONMediaType, next, nil, []byte("\n"), nil)
+}
+
+// ResponseJSONArray stream items as json array written incrementally, each item encoded with json codec.
+// The array is well-formed only if returned StreamResult is completed, see ResponseNDJSON.
+// Call at the end line of your handler.
+// @opts: can be nil, if nil then default is used.
+func ResponseJSONArray(w http.ResponseWriter, r *http.Request, statusCode int, next Iterator, opts *StreamOpts) (StreamResult, error) {
+	s := newJSONStream(w, r, opts)
+	return s.run(statusCode, "application/json", next, []byte("["), []byte(","), []byte("]"))
+}
+
+// jsonStream state of streaming response, buffered between flushes.
+type jsonStream struct {
+	w       http.ResponseWriter
+	ctx     context.Context
+	flusher http.Flusher
+	opts    StreamOpts
+	buf     bytes.Buffer
+	item    bytes.Buffer
+}
+
+func newJSONStream(w http.ResponseWriter, r *http.Request, opts *StreamOpts) *jsonStream {
+	s := &jsonStream{w: w, ctx: r.Context()}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.FlushInterval <= 0 {
+		s.opts.FlushInterval = defaultStreamFlushInterval
+	}
+	if s.opts.FlushItems <= 0 {
+		s.opts.FlushItems = defaultStreamFlushItems
+	}
+	s.flusher, _ = flusherOf(w)
+	return s
+}
+
+// run write items between open and end, separated by sep. For ndjson sep terminates every line instead.
+func (s *jsonStream) run(statusCode int, contentType string, next Iterator, open, sep, end []byte) (StreamResult, error) {
+	var res StreamResult
+	codec, _ := LookupCodec("application/json")
+	ndjson := open == nil
+
+	// iterator runs on request, so it is never ahead of items being encoded.
+	want := make(chan struct{})
+	results := make(chan iterResult, 1)
+	defer close(want)
+	go func() {
+		for range want {
+			item, ok, err := next(s.ctx)
+			results <- iterResult{item, ok, err}
+		}
+	}()
+
+	want <- struct{}{}
+	var r iterResult
+	select {
+	case r = <-results:
+	case <-s.ctx.Done():
+		return res, s.ctx.Err()
+	}
+	if r.err != nil {
+		return res, r.err
+	}
+	h := s.w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Del("Content-Length")
+	responseHeader(s.w, statusCode)
+	s.buf.Write(open)
+
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	var (
+		pending   int
+		lastFlush = time.Now()
+	)
+	for r.ok {
+		if err := s.ctx.Err(); err != nil {
+			return res, err
+		}
+		s.item.Reset()
+		if err := codec.Encode(&s.item, r.item); err != nil {
+			s.flush()
+			return res, err
+		}
+		if !ndjson && res.Items > 0 {
+			s.buf.Write(sep)
+		}
+		// compact, so codecs registered with indentation can't break lines of ndjson.
+		if err := json.Compact(&s.buf, s.item.Bytes()); err != nil {
+			s.flush()
+			return res, err
+		}
+		if ndjson {
+			s.buf.Write(sep)
+		}
+		res.Items++
+		pending++
+		// first item is flushed right away, so client sees the stream start.
+		if res.Items == 1 || pending >= s.opts.FlushItems || time.Since(lastFlush) >= s.opts.FlushInterval {
+			if err := s.flush(); err != nil {
+				return res, err
+			}
+			pending, lastFlush = 0, time.Now()
+		}
+
+		// flush buffered items while iterator is blocked.
+		want <- struct{}{}
+	wait:
+		for {
+			select {
+			case r = <-results:
+				break wait
+			case <-ticker.C:
+				if pending > 0 && time.Since(lastFlush) >= s.opts.FlushInterval {
+					if err := s.flush(); err != nil {
+						return res, err
+					}
+					pending, lastFlush = 0, time.Now()
+				}
+			case <-s.ctx.Done():
+				return res, s.ctx.Err()
+			}
+		}
+		if r.err != nil {
+			s.flush()
+			return res, r.err
+		}
+	}
+	s.buf.Write(end)
+	if err := s.flush(); err != nil {
+		return res, err
+	}
+	res.Completed = true
+	return res, nil
+}
+
+// flush write buffered items and flush them to client.
+func (s *jsonStream) flush() error {
+	if _, err := s.w.Write(s.buf.Bytes()); err != nil {
+		return err
+	}
+	s.buf.Reset()
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
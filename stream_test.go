@@ -0,0 +1,141 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sliceIterator(items ...interface{}) Iterator {
+	i := 0
+	return func(ctx context.Context) (interface{}, bool, error) {
+		if i == len(items) {
+			return nil, false, nil
+		}
+		i++
+		return items[i-1], true, nil
+	}
+}
+
+func TestResponseNDJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/export", nil)
+	res, err := ResponseNDJSON(w, r, http.StatusOK, sliceIterator(map[string]int{"a": 1}, "b\nc", 3), &StreamOpts{FlushItems: 1})
+	if err != nil || !res.Completed || res.Items != 3 {
+		t.Fatalf("%s expected completed stream of 3 items, returned %+v %v", t.Name(), res, err)
+	}
+	if w.Header().Get("Content-Type") != NDJSONMediaType || !w.Flushed {
+		t.Errorf("%s expected flushed ndjson, returned %v", t.Name(), w.Header())
+	}
+	expected := "{\"a\":1}\n\"b\\nc\"\n3\n"
+	if w.Body.String() != expected {
+		t.Errorf("%s expected %q, returned %q", t.Name(), expected, w.Body.String())
+	}
+}
+
+func TestResponseJSONArray(t *testing.T) {
+	ch := make(chan []int, 2)
+	ch <- []int{1}
+	ch <- nil
+	close(ch)
+	tests := []struct {
+		next     Iterator
+		expected string
+	}{
+		{ChanIterator(ch), `[[1],null]`},
+		{sliceIterator(), `[]`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		res, err := ResponseJSONArray(w, httptest.NewRequest("GET", "/", nil), http.StatusOK, tt.next, nil)
+		if err != nil || !res.Completed {
+			t.Errorf("%s expected completed stream, returned %+v %v", t.Name(), res, err)
+		}
+		if w.Body.String() != tt.expected || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s expected %s, returned %s", t.Name(), tt.expected, w.Body.String())
+		}
+	}
+}
+
+func TestResponseJSONArray_Incomplete(t *testing.T) {
+	failed := errors.New("query failed")
+	w := httptest.NewRecorder()
+	_, err := ResponseJSONArray(w, httptest.NewRequest("GET", "/", nil), http.StatusOK, func(ctx context.Context) (interface{}, bool, error) {
+		return nil, false, failed
+	}, nil)
+	if err != failed || w.Body.Len() != 0 || w.Code != http.StatusOK || w.Header().Get("Content-Type") != "" {
+		t.Errorf("%s expected nothing written on immediate failure, returned %v %q", t.Name(), err, w.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan int, 1)
+	ch <- 1
+	calls, next := 0, ChanIterator(ch)
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	res, err := ResponseJSONArray(w, r, http.StatusOK, func(ctx context.Context) (interface{}, bool, error) {
+		// client disconnects while waiting for the second item.
+		if calls++; calls == 2 {
+			cancel()
+		}
+		return next(ctx)
+	}, &StreamOpts{FlushItems: 1})
+	if err != context.Canceled || res.Completed || res.Items != 1 {
+		t.Errorf("%s expected canceled stream after 1 item, returned %+v %v", t.Name(), res, err)
+	}
+	if w.Body.String() != "[1" {
+		t.Errorf("%s expected partial array, returned %q", t.Name(), w.Body.String())
+	}
+
+	_, _, err = ChanIterator(42)(context.Background())
+	if err != ErrNotChannel {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrNotChannel, err)
+	}
+}
+
+func TestResponseNDJSON_SlowIterator(t *testing.T) {
+	ch := make(chan int)
+	done := make(chan StreamResult, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, _ := ResponseNDJSON(w, r, http.StatusOK, ChanIterator(ch), &StreamOpts{FlushInterval: 10 * time.Millisecond})
+		done <- res
+	}))
+	defer ts.Close()
+	// header is sent along with the first item.
+	go func() { ch <- 1 }()
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("%s expected stream to start with first item, returned %v", t.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	// iterator blocks after each item, buffered ones must still reach the client.
+	br := bufio.NewReader(resp.Body)
+	for i := 1; i <= 2; i++ {
+		if i > 1 {
+			ch <- i
+		}
+		line := make(chan string, 1)
+		go func() {
+			s, _ := br.ReadString('\n')
+			line <- s
+		}()
+		select {
+		case s := <-line:
+			if s != strconv.Itoa(i)+"\n" {
+				t.Errorf("%s expected item %d, returned %q", t.Name(), i, s)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s expected item %d flushed while iterator is blocked", t.Name(), i)
+		}
+	}
+	close(ch)
+	if res := <-done; !res.Completed || res.Items != 2 {
+		t.Errorf("%s expected completed stream of 2 items, returned %+v", t.Name(), res)
+	}
+}
@@ -0,0 +1,363 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultUploadMaxFileSize  = 32 << 20
+	defaultUploadMaxTotalSize = 128 << 20
+	defaultUploadMaxValueSize = 1 << 20
+	sniffLength               = 512
+
+	// uploadBodyOverhead bytes of boundaries and part headers allowed on top of MaxTotalSize.
+	uploadBodyOverhead = 1 << 20
+)
+
+var (
+	ErrFileTooLarge       = errors.New("httpserver: uploaded file is too large")
+	ErrUploadTooLarge     = errors.New("httpserver: upload is too large")
+	ErrTooManyFiles       = errors.New("httpserver: too many uploaded files")
+	ErrFileTypeNotAllowed = errors.New("httpserver: uploaded file type is not allowed")
+	ErrNotMultipart       = errors.New("httpserver: request is not multipart/form-data")
+)
+
+type uploadKey struct{}
+
+// UploadOpts options for multipart upload parsing.
+type UploadOpts struct {
+	// MaxFileSize maximum size in bytes of each file. If empty then 32MB is used.
+	MaxFileSize int64
+
+	// MaxTotalSize maximum size in bytes of all parts, including ones without field name which are skipped.
+	// Whole request body is capped at it plus 1MB for boundaries and part headers. If empty then 128MB is used.
+	MaxTotalSize int64
+
+	// MaxFiles maximum number of files. If empty then number of files is not limited.
+	MaxFiles int
+
+	// AllowedTypes media types allowed, detected from file content rather than trusted from client,
+	// e.g. "image/png" or "image/*". If empty then all types are allowed.
+	AllowedTypes []string
+
+	// TempDir directory where files are stored while request is handled. If empty then os.TempDir() is used.
+	TempDir string
+
+	// Checksum hash computed over each file. If empty then SHA-256 is used.
+	Checksum func() hash.Hash
+
+	// Progress optional, called as file is being stored with number of bytes stored so far.
+	Progress func(field string, filename string, written int64)
+}
+
+// UploadedFile file stored into temp dir.
+type UploadedFile struct {
+	// Field form field name.
+	Field string
+
+	// Filename base name of file sent by client, not to be trusted as path.
+	Filename string
+
+	// ContentType media type detected from file content.
+	ContentType string
+
+	// Size in bytes.
+	Size int64
+
+	// Checksum hex encoded checksum of file content.
+	Checksum string
+
+	// Path of the temp file. Removed on cleanup unless moved with MoveTo.
+	Path string
+
+	// Header part header sent by client.
+	Header textproto.MIMEHeader
+
+	moved bool
+}
+
+// Open open stored file for reading.
+func (f *UploadedFile) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// MoveTo move stored file to permanent location, it is no longer removed on cleanup.
+func (f *UploadedFile) MoveTo(path string) error {
+	if err := os.Rename(f.Path, path); err != nil {
+		return err
+	}
+	f.Path = path
+	f.moved = true
+	return nil
+}
+
+// Upload parsed multipart request.
+type Upload struct {
+	// Files in the order they were sent.
+	Files []*UploadedFile
+
+	// Values non file form fields.
+	Values url.Values
+}
+
+// File return the first file of field, nil if not exist.
+func (u *Upload) File(field string) *UploadedFile {
+	for _, f := range u.Files {
+		if f.Field == field {
+			return f
+		}
+	}
+	return nil
+}
+
+// Cleanup remove temp files not moved with MoveTo.
+func (u *Upload) Cleanup() error {
+	var err error
+	for _, f := range u.Files {
+		if f.moved {
+			continue
+		}
+		if e := os.Remove(f.Path); e != nil && !os.IsNotExist(e) && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// ParseUpload stream multipart request parts into temp files without buffering whole files in memory.
+// Caller must call Cleanup once done, or use UploadMiddleware which does it after handler returns.
+// @opts: can be nil, if nil then default is used.
+func ParseUpload(r *http.Request, opts *UploadOpts) (*Upload, error) {
+	return parseUpload(nil, r, opts)
+}
+
+// parseUpload see ParseUpload, w is told to close connection if body exceeds the limit, can be nil.
+func parseUpload(w http.ResponseWriter, r *http.Request, opts *UploadOpts) (*Upload, error) {
+	o := uploadOpts(opts)
+	if r.Body != nil {
+		body := &countingReader{ReadCloser: http.MaxBytesReader(w, r.Body, o.MaxTotalSize+uploadBodyOverhead)}
+		r.Body = body
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, ErrNotMultipart
+	}
+	u := &Upload{Values: make(url.Values)}
+	fail := func(err error) (*Upload, error) {
+		u.Cleanup()
+		if body, ok := r.Body.(*countingReader); ok && body.n >= o.MaxTotalSize+uploadBodyOverhead {
+			err = ErrUploadTooLarge
+		}
+		return nil, err
+	}
+	remaining := o.MaxTotalSize
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return u, nil
+		}
+		if err != nil {
+			return fail(err)
+		}
+		name := part.FormName()
+		if name == "" {
+			// skipped, yet counted so the limit can't be bypassed.
+			n, err := io.Copy(ioutil.Discard, io.LimitReader(part, remaining+1))
+			part.Close()
+			if err != nil {
+				return fail(err)
+			}
+			if n > remaining {
+				return fail(ErrUploadTooLarge)
+			}
+			remaining -= n
+			continue
+		}
+		if part.FileName() == "" {
+			limit := remaining
+			if limit > defaultUploadMaxValueSize {
+				limit = defaultUploadMaxValueSize
+			}
+			b, err := ioutil.ReadAll(io.LimitReader(part, limit+1))
+			part.Close()
+			if err != nil {
+				return fail(err)
+			}
+			if int64(len(b)) > limit {
+				return fail(ErrUploadTooLarge)
+			}
+			remaining -= int64(len(b))
+			u.Values.Add(name, string(b))
+			continue
+		}
+		if o.MaxFiles > 0 && len(u.Files) >= o.MaxFiles {
+			part.Close()
+			return fail(ErrTooManyFiles)
+		}
+		f, err := storeUploadedFile(part, &o, remaining)
+		part.Close()
+		if f != nil {
+			u.Files = append(u.Files, f)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		remaining -= f.Size
+	}
+}
+
+// UploadMiddleware parse multipart request before handler, available via GetUpload, and remove temp files after it returns.
+// Respond 413 if limits are exceeded, 415 if file type is not allowed and 400 for malformed request.
+// @opts: can be nil, if nil then default is used.
+func UploadMiddleware(opts *UploadOpts) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			u, err := parseUpload(w, r, opts)
+			if err != nil {
+				status := http.StatusBadRequest
+				switch err {
+				case ErrFileTooLarge, ErrUploadTooLarge, ErrTooManyFiles:
+					status = http.StatusRequestEntityTooLarge
+				case ErrFileTypeNotAllowed, ErrNotMultipart:
+					status = http.StatusUnsupportedMediaType
+				}
+				ResponseString(w, status, http.StatusText(status))
+				return
+			}
+			defer u.Cleanup()
+			next(w, r.WithContext(context.WithValue(r.Context(), uploadKey{}, u)))
+		}
+	}
+}
+
+// GetUpload return upload parsed by UploadMiddleware.
+func GetUpload(r *http.Request) (*Upload, bool) {
+	u, ok := r.Context().Value(uploadKey{}).(*Upload)
+	return u, ok
+}
+
+func uploadOpts(opts *UploadOpts) UploadOpts {
+	var o UploadOpts
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxFileSize <= 0 {
+		o.MaxFileSize = defaultUploadMaxFileSize
+	}
+	if o.MaxTotalSize <= 0 {
+		o.MaxTotalSize = defaultUploadMaxTotalSize
+	}
+	if o.TempDir == "" {
+		o.TempDir = os.TempDir()
+	}
+	if o.Checksum == nil {
+		o.Checksum = sha256.New
+	}
+	return o
+}
+
+// storeUploadedFile copy part into temp file, checking its sniffed type and size on the way.
+// Return stored file along with error so caller can clean it up.
+func storeUploadedFile(part *multipart.Part, o *UploadOpts, remaining int64) (*UploadedFile, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !uploadTypeAllowed(o.AllowedTypes, contentType) {
+		return nil, ErrFileTypeNotAllowed
+	}
+
+	tmp, err := ioutil.TempFile(o.TempDir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+	f := &UploadedFile{
+		Field:       part.FormName(),
+		Filename:    filepath.Base(part.FileName()),
+		ContentType: contentType,
+		Path:        tmp.Name(),
+		Header:      part.Header,
+	}
+
+	limit := o.MaxFileSize
+	tooLarge := ErrFileTooLarge
+	if remaining < limit {
+		limit, tooLarge = remaining, ErrUploadTooLarge
+	}
+	h := o.Checksum()
+	pw := &progressWriter{w: io.MultiWriter(tmp, h), field: f.Field, filename: f.Filename, progress: o.Progress}
+	written, err := io.Copy(pw, io.LimitReader(io.MultiReader(bytes.NewReader(head), part), limit+1))
+	f.Size = written
+	if err != nil {
+		return f, err
+	}
+	if written > limit {
+		return f, tooLarge
+	}
+	f.Checksum = hex.EncodeToString(h.Sum(nil))
+	return f, nil
+}
+
+func uploadTypeAllowed(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, v := range allowed {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == contentType || v == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(v, "/*") && strings.HasPrefix(contentType, v[:len(v)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader count bytes read from body, to tell body limit apart from other read errors.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+type progressWriter struct {
+	w        io.Writer
+	field    string
+	filename string
+	written  int64
+	progress func(field string, filename string, written int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	if pw.progress != nil {
+		pw.progress(pw.field, pw.filename, pw.written)
+	}
+	return n, err
+}
@@ -0,0 +1,141 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func testMultipartRequest(files map[string][]byte, values map[string]string) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range values {
+		mw.WriteField(k, v)
+	}
+	for name, content := range files {
+		fw, _ := mw.CreateFormFile(name, "../../"+name+".bin")
+		fw.Write(content)
+	}
+	mw.Close()
+	r := httptest.NewRequest("POST", "/upload", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestParseUpload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "upload-test")
+	defer os.RemoveAll(dir)
+	var progress int64
+	r := testMultipartRequest(map[string][]byte{"avatar": testPNG}, map[string]string{"name": "john"})
+	u, err := ParseUpload(r, &UploadOpts{
+		AllowedTypes: []string{"image/*"},
+		TempDir:      dir,
+		Progress: func(field string, filename string, written int64) {
+			progress = written
+		},
+	})
+	if err != nil {
+		t.Fatalf("%s expected null error, returned %v", t.Name(), err)
+	}
+	f := u.File("avatar")
+	sum := sha256.Sum256(testPNG)
+	if f == nil || f.ContentType != "image/png" || f.Size != int64(len(testPNG)) || f.Checksum != hex.EncodeToString(sum[:]) ||
+		f.Filename != "avatar.bin" || filepath.Dir(f.Path) != dir {
+		t.Errorf("%s expected stored png file, returned %+v", t.Name(), f)
+	}
+	if progress != int64(len(testPNG)) {
+		t.Errorf("%s expected progress %d, returned %d", t.Name(), len(testPNG), progress)
+	}
+	if u.Values.Get("name") != "john" {
+		t.Errorf("%s expected value john, returned %s", t.Name(), u.Values.Get("name"))
+	}
+	u.Cleanup()
+	if _, err := os.Stat(f.Path); !os.IsNotExist(err) {
+		t.Errorf("%s expected temp file removed, returned %v", t.Name(), err)
+	}
+}
+
+func TestParseUpload_Limits(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "upload-test")
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		opts *UploadOpts
+		err  error
+	}{
+		{&UploadOpts{TempDir: dir, AllowedTypes: []string{"application/pdf"}}, ErrFileTypeNotAllowed},
+		{&UploadOpts{TempDir: dir, MaxFileSize: 10}, ErrFileTooLarge},
+		{&UploadOpts{TempDir: dir, MaxTotalSize: 50}, ErrUploadTooLarge},
+	}
+	for _, tt := range tests {
+		r := testMultipartRequest(map[string][]byte{"avatar": testPNG}, nil)
+		if _, err := ParseUpload(r, tt.opts); err != tt.err {
+			t.Errorf("%s expected %v, returned %v", t.Name(), tt.err, err)
+		}
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%s expected no temp file left, found %d", t.Name(), len(files))
+	}
+}
+
+func TestUploadMiddleware(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "upload-test")
+	defer os.RemoveAll(dir)
+	var path string
+	h := UploadMiddleware(&UploadOpts{TempDir: dir, MaxFiles: 1})(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := GetUpload(r)
+		if !ok {
+			t.Fatalf("%s expected upload in context", t.Name())
+		}
+		path = u.File("avatar").Path
+	})
+	w := httptest.NewRecorder()
+	h(w, testMultipartRequest(map[string][]byte{"avatar": testPNG}, nil))
+	if _, err := os.Stat(path); path == "" || !os.IsNotExist(err) {
+		t.Errorf("%s expected temp file removed after handler, returned %v", t.Name(), err)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, testMultipartRequest(map[string][]byte{"a": testPNG, "b": testPNG}, nil))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestParseUpload_NamelessParts(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for i := 0; i < 10; i++ {
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Disposition": {"form-data"}})
+		pw.Write(bytes.Repeat([]byte("x"), 500))
+	}
+	mw.Close()
+	r := httptest.NewRequest("POST", "/upload", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	UploadMiddleware(&UploadOpts{MaxTotalSize: 2000})(func(w http.ResponseWriter, r *http.Request) {})(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("%s expected %d for nameless parts over the limit, returned %d", t.Name(), http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	// part headers are not counted per part, the body cap bounds them.
+	buf.Reset()
+	mw = multipart.NewWriter(&buf)
+	mw.CreatePart(textproto.MIMEHeader{"Content-Disposition": {`form-data; name="name"`}, "X-Pad": {strings.Repeat("x", 2<<20)}})
+	mw.Close()
+	r = httptest.NewRequest("POST", "/upload", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if _, err := ParseUpload(r, &UploadOpts{MaxTotalSize: 100}); err != ErrUploadTooLarge {
+		t.Errorf("%s expected %v for body over the cap, returned %v", t.Name(), ErrUploadTooLarge, err)
+	}
+}
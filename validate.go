@@ -0,0 +1,473 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var ErrInvalidRule = errors.New("httpserver: invalid validation rule")
+
+// RuleFunc validation rule, return whether value is valid.
+// @param: rule parameter, e.g. "3" of "min=3", empty if rule has none.
+type RuleFunc func(v reflect.Value, param string) bool
+
+// validator registry of rules and localized messages.
+type validator struct {
+	mu       sync.RWMutex
+	rules    map[string]RuleFunc
+	messages map[string]map[string]string
+	regexps  sync.Map
+
+	// inspected struct types whose rules are known to be valid.
+	inspected sync.Map
+}
+
+const defaultValidationLanguage = "en"
+
+var defaultValidator = &validator{
+	rules: map[string]RuleFunc{
+		"required": ruleRequired,
+		"min":      ruleMin,
+		"max":      ruleMax,
+		"len":      ruleLen,
+		"email":    ruleEmail,
+		"oneof":    ruleOneOf,
+	},
+	messages: map[string]map[string]string{
+		defaultValidationLanguage: {
+			"required": "is required",
+			"min":      "must be at least {param}",
+			"max":      "must be at most {param}",
+			"len":      "must have length of {param}",
+			"email":    "must be a valid email address",
+			"regexp":   "has invalid format",
+			"oneof":    "must be one of {param}",
+			"":         "is invalid",
+		},
+	},
+}
+
+// RegisterRule add custom validation rule usable in `validate` tag, e.g. RegisterRule("even", ...) for `validate:"even"`.
+// Built-in rules with the same name are replaced.
+func RegisterRule(name string, fn RuleFunc) {
+	defaultValidator.mu.Lock()
+	defer defaultValidator.mu.Unlock()
+	defaultValidator.rules[name] = fn
+}
+
+// RegisterMessages add messages of rules for language, e.g. "id", keyed by rule name.
+// "{param}" in message is replaced with rule parameter. Key "" is the fallback message of rules without one.
+func RegisterMessages(lang string, messages map[string]string) {
+	defaultValidator.mu.Lock()
+	defer defaultValidator.mu.Unlock()
+	lang = strings.ToLower(lang)
+	if defaultValidator.messages[lang] == nil {
+		defaultValidator.messages[lang] = make(map[string]string)
+	}
+	for k, v := range messages {
+		defaultValidator.messages[lang][k] = v
+	}
+}
+
+// Validate check struct fields against rules in their `validate` tag, nested structs included.
+// Built-in rules: required, omitempty, min, max, len, email, oneof, regexp and dive,
+// e.g. `validate:"required,min=3"`, `validate:"oneof=draft published"` or `validate:"max=5,dive,email"`.
+// Rules after dive apply to each element of slice or map. regexp consumes the rest of the tag, so it must be the last rule.
+// Return FieldErrors with rule name as code if some fields are invalid,
+// or ErrInvalidRule if tag has unknown rule or invalid regexp.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ErrInvalidBindTarget
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ErrInvalidBindTarget
+	}
+	if err := defaultValidator.inspect(rv.Type(), make(map[reflect.Type]bool)); err != nil {
+		return err
+	}
+	var errs FieldErrors
+	defaultValidator.validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// BindAndValidate Bind request into dst then Validate it.
+func BindAndValidate(r *http.Request, dst interface{}) error {
+	if err := Bind(r, dst); err != nil {
+		return err
+	}
+	return Validate(dst)
+}
+
+// ResponseValidationError response error returned by Bind, Validate or BindAndValidate.
+// FieldErrors result in 422 listing every failing field, messages of failed rules are localized by Accept-Language,
+// unsupported media type in 415, invalid rules in 500 and the rest in 400.
+func ResponseValidationError(w http.ResponseWriter, r *http.Request, err error) error {
+	var fe FieldErrors
+	switch {
+	case errors.As(err, &fe):
+		lang := defaultValidator.language(r.Header.Get("Accept-Language"))
+		fields := make(FieldErrors, len(fe))
+		for i, e := range fe {
+			// only rule failures are localized, Bind errors carry specific messages, e.g. expected type.
+			if e.Code != "" && e.Source == "" {
+				e.Message = defaultValidator.message(lang, e.Code, e.Param)
+			}
+			fields[i] = e
+		}
+		return ResponseJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  "validation failed",
+			"fields": fields,
+		})
+	case errors.Is(err, ErrInvalidRule):
+		return ResponseJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": http.StatusText(http.StatusInternalServerError)})
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return ResponseJSON(w, http.StatusUnsupportedMediaType, map[string]interface{}{"error": http.StatusText(http.StatusUnsupportedMediaType)})
+	}
+	return ResponseJSON(w, http.StatusBadRequest, map[string]interface{}{"error": http.StatusText(http.StatusBadRequest)})
+}
+
+// inspect check rules of struct type t and types nested in it, once per type.
+func (vd *validator) inspect(t reflect.Type, visiting map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+	if _, ok := vd.inspected.Load(t); ok {
+		return nil
+	}
+	visiting[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		for _, rule := range splitRules(sf.Tag.Get("validate")) {
+			if err := vd.checkRule(rule); err != nil {
+				return fmt.Errorf("%w of field %s.%s: %v", ErrInvalidRule, t.Name(), sf.Name, err)
+			}
+		}
+		if err := vd.inspect(sf.Type, visiting); err != nil {
+			return err
+		}
+	}
+	vd.inspected.Store(t, true)
+	return nil
+}
+
+// checkRule return error if rule is unknown or its regexp doesn't compile.
+func (vd *validator) checkRule(rule string) error {
+	code, param := rule, ""
+	if j := strings.Index(rule, "="); j >= 0 {
+		code, param = rule[:j], rule[j+1:]
+	}
+	switch code {
+	case "omitempty", "dive", "required":
+		return nil
+	case "regexp":
+		_, err := vd.regexp(param)
+		return err
+	}
+	vd.mu.RLock()
+	_, ok := vd.rules[code]
+	vd.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown rule %q", code)
+	}
+	return nil
+}
+
+func (vd *validator) regexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := vd.regexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	re, _ := vd.regexps.LoadOrStore(expr, compiled)
+	return re.(*regexp.Regexp), nil
+}
+
+func (vd *validator) validateStruct(v reflect.Value, prefix string, errs *FieldErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			vd.validateStruct(fv, prefix, errs)
+			continue
+		}
+		name := prefix + fieldName(sf)
+		vd.validateValue(fv, name, splitRules(sf.Tag.Get("validate")), errs)
+	}
+}
+
+// validateValue apply rules to v, then descend into structs and, with dive, into elements.
+func (vd *validator) validateValue(v reflect.Value, name string, rules []string, errs *FieldErrors) {
+	var dive []string
+	for i, rule := range rules {
+		if rule == "dive" {
+			rules, dive = rules[:i], rules[i+1:]
+			break
+		}
+	}
+	if len(rules) > 0 && rules[0] == "omitempty" {
+		if isZero(v) {
+			return
+		}
+		rules = rules[1:]
+	}
+	for _, rule := range rules {
+		code, param := rule, ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			code, param = rule[:j], rule[j+1:]
+		}
+		if code == "required" {
+			if isZero(v) {
+				*errs = append(*errs, vd.fieldError(name, code, param))
+				return
+			}
+			continue
+		}
+		// other rules don't apply to absent values, combine with required to enforce presence.
+		value := v
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				break
+			}
+			value = value.Elem()
+		}
+		if (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && value.IsNil() {
+			continue
+		}
+		if !vd.check(value, code, param) {
+			*errs = append(*errs, vd.fieldError(name, code, param))
+		}
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		vd.validateStruct(v, name+".", errs)
+	case reflect.Slice, reflect.Array:
+		if dive == nil && !hasStructElem(v.Type()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			vd.validateValue(v.Index(i), fmt.Sprintf("%s[%d]", name, i), dive, errs)
+		}
+	case reflect.Map:
+		if dive == nil && !hasStructElem(v.Type()) {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			vd.validateValue(iter.Value(), fmt.Sprintf("%s[%v]", name, iter.Key()), dive, errs)
+		}
+	}
+}
+
+func (vd *validator) check(v reflect.Value, code string, param string) bool {
+	if code == "regexp" {
+		re, err := vd.regexp(param)
+		return err == nil && v.Kind() == reflect.String && re.MatchString(v.String())
+	}
+	vd.mu.RLock()
+	fn, ok := vd.rules[code]
+	vd.mu.RUnlock()
+	// rules are checked by inspect beforehand, unknown one can only be reached through interface values.
+	return ok && fn(v, param)
+}
+
+func (vd *validator) fieldError(name string, code string, param string) FieldError {
+	return FieldError{
+		Field:   name,
+		Code:    code,
+		Param:   param,
+		Message: vd.message(defaultValidationLanguage, code, param),
+	}
+}
+
+func (vd *validator) message(lang string, code string, param string) string {
+	vd.mu.RLock()
+	defer vd.mu.RUnlock()
+	msg, ok := vd.messages[lang][code]
+	if !ok {
+		msg, ok = vd.messages[defaultValidationLanguage][code]
+	}
+	if !ok {
+		msg, ok = vd.messages[lang][""]
+	}
+	if !ok {
+		msg = vd.messages[defaultValidationLanguage][""]
+	}
+	return strings.Replace(msg, "{param}", param, -1)
+}
+
+// language pick the most preferred language from Accept-Language having messages, e.g. "id-ID" falls back to "id".
+func (vd *validator) language(acceptLanguage string) string {
+	vd.mu.RLock()
+	defer vd.mu.RUnlock()
+	best, bestQ := defaultValidationLanguage, 0.0
+	for _, v := range strings.Split(acceptLanguage, ",") {
+		lang, q := parseQValue(v)
+		lang = strings.ToLower(lang)
+		if q <= bestQ {
+			continue
+		}
+		if _, ok := vd.messages[lang]; ok {
+			best, bestQ = lang, q
+			continue
+		}
+		if i := strings.Index(lang, "-"); i > 0 {
+			if _, ok := vd.messages[lang[:i]]; ok {
+				best, bestQ = lang[:i], q
+			}
+		}
+	}
+	return best
+}
+
+// fieldName name of field as sent by client, taken from json, form, query, path or header tag.
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "xml", "form", "query", "path", "header"} {
+		if name := tagName(sf, tag); name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// splitRules split tag by commas, except regexp rule which takes the rest of the tag since pattern may contain commas.
+func splitRules(tag string) []string {
+	if tag == "" || tag == "-" {
+		return nil
+	}
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regexp=") {
+			return append(rules, tag)
+		}
+		rule := tag
+		if i := strings.Index(tag, ","); i >= 0 {
+			rule, tag = tag[:i], tag[i+1:]
+		} else {
+			tag = ""
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func hasStructElem(t reflect.Type) bool {
+	e := t.Elem()
+	for e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+	return e.Kind() == reflect.Struct
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+// size length of strings, in characters, and collections, or value of numbers.
+func size(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func ruleRequired(v reflect.Value, param string) bool {
+	return !isZero(v)
+}
+
+func ruleMin(v reflect.Value, param string) bool {
+	n, ok := size(v)
+	limit, err := strconv.ParseFloat(param, 64)
+	return ok && err == nil && n >= limit
+}
+
+func ruleMax(v reflect.Value, param string) bool {
+	n, ok := size(v)
+	limit, err := strconv.ParseFloat(param, 64)
+	return ok && err == nil && n <= limit
+}
+
+func ruleLen(v reflect.Value, param string) bool {
+	if v.Kind() != reflect.String && v.Kind() != reflect.Slice && v.Kind() != reflect.Array && v.Kind() != reflect.Map {
+		return false
+	}
+	n, _ := size(v)
+	limit, err := strconv.ParseFloat(param, 64)
+	return err == nil && n == limit
+}
+
+func ruleEmail(v reflect.Value, param string) bool {
+	if v.Kind() != reflect.String {
+		return false
+	}
+	addr, err := mail.ParseAddress(v.String())
+	return err == nil && addr.Address == v.String()
+}
+
+func ruleOneOf(v reflect.Value, param string) bool {
+	var s string
+	switch v.Kind() {
+	case reflect.String:
+		s = v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(v.Uint(), 10)
+	default:
+		return false
+	}
+	for _, option := range strings.Fields(param) {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
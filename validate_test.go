@@ -0,0 +1,161 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"len=5,regexp=^[0-9]+$"`
+}
+
+type testUser struct {
+	Name      string         `json:"name" validate:"required,min=3,max=10"`
+	Email     string         `json:"email" validate:"required,email"`
+	Status    string         `json:"status" validate:"oneof=draft published"`
+	Age       *int           `json:"age" validate:"omitempty,min=18"`
+	Tags      []string       `json:"tags" validate:"max=2,dive,min=2"`
+	Address   testAddress    `json:"address"`
+	Addresses []*testAddress `json:"addresses"`
+	Count     int            `json:"count" validate:"even"`
+}
+
+func testFieldCodes(err error) map[string]string {
+	codes := make(map[string]string)
+	if fe, ok := err.(FieldErrors); ok {
+		for _, e := range fe {
+			codes[e.Field] = e.Code
+		}
+	}
+	return codes
+}
+
+// registerTestRules register custom rules used by testUser.
+func registerTestRules() {
+	RegisterRule("even", func(v reflect.Value, param string) bool {
+		return v.Kind() == reflect.Int && v.Int()%2 == 0
+	})
+}
+
+func TestValidate(t *testing.T) {
+	registerTestRules()
+	age := 10
+	u := &testUser{
+		Name:      "jo",
+		Email:     "not-an-email",
+		Status:    "deleted",
+		Age:       &age,
+		Tags:      []string{"ok", "x", "zz"},
+		Address:   testAddress{Zip: "12a45"},
+		Addresses: []*testAddress{{City: "x", Zip: "12345"}, {Zip: "1"}},
+		Count:     3,
+	}
+	codes := testFieldCodes(Validate(u))
+	expected := map[string]string{
+		"name":              "min",
+		"email":             "email",
+		"status":            "oneof",
+		"age":               "min",
+		"tags":              "max",
+		"tags[1]":           "min",
+		"address.city":      "required",
+		"address.zip":       "regexp",
+		"addresses[1].city": "required",
+		"addresses[1].zip":  "len",
+		"count":             "even",
+	}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, codes)
+	}
+
+	valid := &testUser{Name: "john", Email: "john@example.com", Status: "draft", Address: testAddress{City: "x", Zip: "12345"}}
+	if err := Validate(valid); err != nil {
+		t.Errorf("%s expected null error, returned %v", t.Name(), err)
+	}
+}
+
+func TestResponseValidationError(t *testing.T) {
+	registerTestRules()
+	RegisterMessages("id", map[string]string{"required": "wajib diisi"})
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"john"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept-Language", "fr;q=0.9, id-ID")
+	var u testUser
+	err := BindAndValidate(r, &u)
+	if err == nil {
+		t.Fatalf("%s expected error, returned null", t.Name())
+	}
+	w := httptest.NewRecorder()
+	ResponseValidationError(w, r, err)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusUnprocessableEntity, w.Code)
+	}
+	var body struct {
+		Fields FieldErrors `json:"fields"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	found := false
+	for _, f := range body.Fields {
+		if f.Field == "email" && f.Code == "required" {
+			found = f.Message == "wajib diisi"
+		}
+	}
+	if !found {
+		t.Errorf("%s expected localized required message of email, returned %s", t.Name(), w.Body.String())
+	}
+
+	r = httptest.NewRequest("POST", "/users", strings.NewReader(`{"age":"ten"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept-Language", "id")
+	w = httptest.NewRecorder()
+	ResponseValidationError(w, r, BindAndValidate(r, &u))
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if len(body.Fields) != 1 || body.Fields[0].Code != "type" || body.Fields[0].Message != "must be int" {
+		t.Errorf("%s expected bind type message kept, returned %s", t.Name(), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	ResponseValidationError(w, r, ErrUnsupportedMediaType)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusUnsupportedMediaType, w.Code)
+	}
+}
+
+func TestValidate_Rules(t *testing.T) {
+	type code struct {
+		Country string `json:"country" validate:"required,regexp=^[a-z]{2,5}$"`
+	}
+	if err := Validate(&code{Country: "idn"}); err != nil {
+		t.Errorf("%s expected valid country, returned %v", t.Name(), err)
+	}
+	if codes := testFieldCodes(Validate(&code{Country: "indonesia"})); codes["country"] != "regexp" {
+		t.Errorf("%s expected regexp error, returned %v", t.Name(), codes)
+	}
+
+	type unknown struct {
+		Name string `validate:"required,nonexistent"`
+	}
+	type nested struct {
+		Items []unknown
+	}
+	type badRegexp struct {
+		Name string `validate:"regexp=[a-"`
+	}
+	for _, v := range []interface{}{&unknown{}, &nested{}, &badRegexp{}} {
+		err := Validate(v)
+		if !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%s expected %v for %T, returned %v", t.Name(), ErrInvalidRule, v, err)
+		}
+		w := httptest.NewRecorder()
+		ResponseValidationError(w, httptest.NewRequest("POST", "/", nil), err)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s expected 500 for %T, returned %d", t.Name(), v, w.Code)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWebhookMaxBodySize = 1 << 20
+	defaultWebhookTolerance   = 5 * time.Minute
+)
+
+var (
+	ErrWebhookSignature = errors.New("httpserver: invalid webhook signature")
+	ErrWebhookTimestamp = errors.New("httpserver: webhook timestamp out of tolerance")
+)
+
+// WebhookScheme describe how a provider signs its webhooks.
+type WebhookScheme struct {
+	// Hash hash function of HMAC, e.g. sha256.New.
+	Hash func() hash.Hash
+
+	// Extract return signatures sent with request, and its signing time if provider signs timestamp.
+	// Return zero time if request carries no timestamp.
+	Extract func(r *http.Request) (signatures [][]byte, timestamp time.Time, err error)
+
+	// Payload optional, build signed content from request body. If empty then body is signed as is.
+	Payload func(r *http.Request, body []byte) []byte
+}
+
+// WebhookOpts options for webhook signature verification middleware.
+type WebhookOpts struct {
+	// Secrets shared secrets, any of them is accepted to allow rotation. Required.
+	Secrets []string
+
+	// Scheme provider signing scheme, e.g. GitHubWebhook() or a custom one. Required.
+	Scheme *WebhookScheme
+
+	// MaxBodySize maximum body size in bytes read for verification. If empty then 1MB is used.
+	MaxBodySize int64
+
+	// Tolerance maximum age of signed timestamp, for schemes carrying one. If empty then 5 minutes is used.
+	Tolerance time.Duration
+
+	// Failure optional, triggered if verification failed. If empty then default 401 response is used.
+	Failure http.HandlerFunc
+}
+
+// Webhook middleware verifying HMAC signature of webhook requests.
+// Body is buffered for verification and restored for the handler.
+func Webhook(opts *WebhookOpts) Middleware {
+	if opts == nil || len(opts.Secrets) == 0 || opts.Scheme == nil {
+		panic("httpserver: WebhookOpts.Secrets and WebhookOpts.Scheme are required")
+	}
+	o := *opts
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultWebhookMaxBodySize
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = defaultWebhookTolerance
+	}
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			limited := &countingReader{ReadCloser: http.MaxBytesReader(w, r.Body, o.MaxBodySize)}
+			body, err := ioutil.ReadAll(limited)
+			r.Body.Close()
+			if err != nil {
+				// only reaching the limit is too large, client disconnects and truncated bodies are bad requests.
+				if limited.n >= o.MaxBodySize {
+					ResponseString(w, http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+					return
+				}
+				ResponseString(w, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+				return
+			}
+			if err := verifyWebhook(r, body, &o); err != nil {
+				if o.Failure != nil {
+					o.Failure(w, r)
+					return
+				}
+				ResponseString(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			next(w, r)
+		}
+	}
+}
+
+func verifyWebhook(r *http.Request, body []byte, o *WebhookOpts) error {
+	signatures, timestamp, err := o.Scheme.Extract(r)
+	if err != nil {
+		return err
+	}
+	if len(signatures) == 0 {
+		return ErrWebhookSignature
+	}
+	if !timestamp.IsZero() {
+		age := time.Since(timestamp)
+		if age > o.Tolerance || age < -o.Tolerance {
+			return ErrWebhookTimestamp
+		}
+	}
+	payload := body
+	if o.Scheme.Payload != nil {
+		payload = o.Scheme.Payload(r, body)
+	}
+	for _, secret := range o.Secrets {
+		mac := hmac.New(o.Scheme.Hash, []byte(secret))
+		mac.Write(payload)
+		expected := mac.Sum(nil)
+		for _, sig := range signatures {
+			if hmac.Equal(expected, sig) {
+				return nil
+			}
+		}
+	}
+	return ErrWebhookSignature
+}
+
+// HexSignatureHeader extractor of hex encoded signature sent in header, with optional prefix, e.g. "sha256=".
+func HexSignatureHeader(header string, prefix string) func(r *http.Request) ([][]byte, time.Time, error) {
+	return func(r *http.Request) ([][]byte, time.Time, error) {
+		v := r.Header.Get(header)
+		if !strings.HasPrefix(v, prefix) {
+			return nil, time.Time{}, ErrWebhookSignature
+		}
+		sig, err := hex.DecodeString(strings.TrimPrefix(v, prefix))
+		if err != nil {
+			return nil, time.Time{}, ErrWebhookSignature
+		}
+		return [][]byte{sig}, time.Time{}, nil
+	}
+}
+
+// Base64SignatureHeader extractor of base64 encoded signature sent in header.
+func Base64SignatureHeader(header string) func(r *http.Request) ([][]byte, time.Time, error) {
+	return func(r *http.Request) ([][]byte, time.Time, error) {
+		sig, err := base64.StdEncoding.DecodeString(r.Header.Get(header))
+		if err != nil || len(sig) == 0 {
+			return nil, time.Time{}, ErrWebhookSignature
+		}
+		return [][]byte{sig}, time.Time{}, nil
+	}
+}
+
+// GitHubWebhook scheme of GitHub, signature in X-Hub-Signature-256 header.
+func GitHubWebhook() *WebhookScheme {
+	return &WebhookScheme{
+		Hash:    sha256.New,
+		Extract: HexSignatureHeader("X-Hub-Signature-256", "sha256="),
+	}
+}
+
+// GitHubWebhookSHA1 legacy scheme of GitHub, signature in X-Hub-Signature header.
+func GitHubWebhookSHA1() *WebhookScheme {
+	return &WebhookScheme{
+		Hash:    sha1.New,
+		Extract: HexSignatureHeader("X-Hub-Signature", "sha1="),
+	}
+}
+
+// ShopifyWebhook scheme of Shopify, signature in X-Shopify-Hmac-Sha256 header.
+func ShopifyWebhook() *WebhookScheme {
+	return &WebhookScheme{
+		Hash:    sha256.New,
+		Extract: Base64SignatureHeader("X-Shopify-Hmac-Sha256"),
+	}
+}
+
+// StripeWebhook scheme of Stripe, signatures and timestamp in Stripe-Signature header, e.g. "t=1492774577,v1=5257a8...".
+func StripeWebhook() *WebhookScheme {
+	return &WebhookScheme{
+		Hash: sha256.New,
+		Extract: func(r *http.Request) ([][]byte, time.Time, error) {
+			var (
+				sigs [][]byte
+				ts   time.Time
+			)
+			for _, part := range strings.Split(r.Header.Get("Stripe-Signature"), ",") {
+				kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				switch kv[0] {
+				case "t":
+					sec, err := strconv.ParseInt(kv[1], 10, 64)
+					if err != nil {
+						return nil, time.Time{}, ErrWebhookTimestamp
+					}
+					ts = time.Unix(sec, 0)
+				case "v1":
+					if sig, err := hex.DecodeString(kv[1]); err == nil {
+						sigs = append(sigs, sig)
+					}
+				}
+			}
+			if ts.IsZero() {
+				return nil, time.Time{}, ErrWebhookTimestamp
+			}
+			return sigs, ts, nil
+		},
+		Payload: func(r *http.Request, body []byte) []byte {
+			t := ""
+			for _, part := range strings.Split(r.Header.Get("Stripe-Signature"), ",") {
+				if strings.HasPrefix(strings.TrimSpace(part), "t=") {
+					t = strings.TrimPrefix(strings.TrimSpace(part), "t=")
+					break
+				}
+			}
+			return append([]byte(t+"."), body...)
+		},
+	}
+}
+
+// SlackWebhook scheme of Slack, signature in X-Slack-Signature and timestamp in X-Slack-Request-Timestamp header.
+func SlackWebhook() *WebhookScheme {
+	return &WebhookScheme{
+		Hash: sha256.New,
+		Extract: func(r *http.Request) ([][]byte, time.Time, error) {
+			sec, err := strconv.ParseInt(r.Header.Get("X-Slack-Request-Timestamp"), 10, 64)
+			if err != nil {
+				return nil, time.Time{}, ErrWebhookTimestamp
+			}
+			sigs, _, err := HexSignatureHeader("X-Slack-Signature", "v0=")(r)
+			return sigs, time.Unix(sec, 0), err
+		},
+		Payload: func(r *http.Request, body []byte) []byte {
+			return append([]byte("v0:"+r.Header.Get("X-Slack-Request-Timestamp")+":"), body...)
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package httpserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testWebhookSign(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhook_GitHub(t *testing.T) {
+	body := `{"action":"opened"}`
+	var received string
+	h := Webhook(&WebhookOpts{
+		Secrets: []string{"new", "old"},
+		Scheme:  GitHubWebhook(),
+	})(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+	})
+	tests := []struct {
+		signature string
+		status    int
+	}{
+		{"sha256=" + testWebhookSign("new", body), http.StatusOK},
+		{"sha256=" + testWebhookSign("old", body), http.StatusOK},
+		{"sha256=" + testWebhookSign("other", body), http.StatusUnauthorized},
+		{testWebhookSign("new", body), http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		received = ""
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		r.Header.Set("X-Hub-Signature-256", tt.signature)
+		h(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s expected %d for %q, returned %d", t.Name(), tt.status, tt.signature, w.Code)
+		}
+		if tt.status == http.StatusOK && received != body {
+			t.Errorf("%s expected body %s restored, returned %s", t.Name(), body, received)
+		}
+	}
+}
+
+func TestWebhook_Stripe(t *testing.T) {
+	body := `{"id":"evt_1"}`
+	h := Webhook(&WebhookOpts{
+		Secrets:   []string{"whsec"},
+		Scheme:    StripeWebhook(),
+		Tolerance: time.Minute,
+	})(func(w http.ResponseWriter, r *http.Request) {})
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	tests := []struct {
+		signature string
+		status    int
+	}{
+		{"t=" + now + ",v1=" + testWebhookSign("whsec", now+"."+body), http.StatusOK},
+		{"t=" + now + ",v1=deadbeef,v1=" + testWebhookSign("whsec", now+"."+body), http.StatusOK},
+		{"t=" + old + ",v1=" + testWebhookSign("whsec", old+"."+body), http.StatusUnauthorized},
+		{"v1=" + testWebhookSign("whsec", now+"."+body), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		r.Header.Set("Stripe-Signature", tt.signature)
+		h(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s expected %d for %q, returned %d", t.Name(), tt.status, tt.signature, w.Code)
+		}
+	}
+}
+
+func TestWebhook_BodyTooLarge(t *testing.T) {
+	h := Webhook(&WebhookOpts{
+		Secrets:     []string{"secret"},
+		Scheme:      GitHubWebhook(),
+		MaxBodySize: 4,
+	})(func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/webhook", strings.NewReader("too large"))
+	r.Header.Set("X-Hub-Signature-256", "sha256="+testWebhookSign("secret", "too large"))
+	h(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestWebhook_BodyReadError(t *testing.T) {
+	h := Webhook(&WebhookOpts{
+		Secrets: []string{"secret"},
+		Scheme:  GitHubWebhook(),
+	})(func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/webhook", io.MultiReader(strings.NewReader("trunc"), errReader{io.ErrUnexpectedEOF}))
+	r.Header.Set("X-Hub-Signature-256", "sha256="+testWebhookSign("secret", "trunc"))
+	h(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusBadRequest, w.Code)
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,738 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types of websocket data frames, see RFC 6455 section 5.6.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Close codes, see RFC 6455 section 7.4.1.
+const (
+	CloseNormal             = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatus           = 1005
+	CloseAbnormal           = 1006
+	CloseInvalidPayload     = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseMandatoryExtension = 1010
+	CloseInternalError      = 1011
+)
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	defaultWSMaxMessageSize = 1 << 20
+	defaultWSPingInterval   = 30 * time.Second
+	defaultWSWriteTimeout   = 10 * time.Second
+
+	// wsCompressThreshold messages shorter than it are sent uncompressed, deflate would hardly shrink them.
+	wsCompressThreshold = 64
+
+	// wsWindowSize deflate window, the data kept for context takeover.
+	wsWindowSize = 1 << 15
+)
+
+var (
+	ErrWSClosed        = errors.New("httpserver: websocket is closed")
+	ErrWSBadHandshake  = errors.New("httpserver: invalid websocket handshake")
+	ErrWSOriginDenied  = errors.New("httpserver: websocket origin not allowed")
+	ErrWSMessageType   = errors.New("httpserver: invalid websocket message type")
+	wsFlushTail        = []byte{0x00, 0x00, 0xff, 0xff}
+	wsDeflateExtension = "permessage-deflate"
+)
+
+// CloseError close frame received from, or sent to, the peer.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("httpserver: websocket closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("httpserver: websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+// WSOpts options of websocket endpoints.
+type WSOpts struct {
+	// CheckOrigin return whether browser request from Origin is allowed.
+	// If empty then only requests without Origin or with Origin of the same scheme and host as seen by the client are allowed.
+	CheckOrigin func(r *http.Request) bool
+
+	// Subprotocols supported by server in order of preference, the first one offered by client is selected.
+	Subprotocols []string
+
+	// MaxMessageSize maximum size in bytes of received message, after decompression. If empty then 1MB is used.
+	MaxMessageSize int64
+
+	// Compression negotiate permessage-deflate extension, see RFC 7692.
+	Compression bool
+
+	// PingInterval interval of pings, connection is closed if nothing is received for twice as long.
+	// If empty then 30s is used, negative disables pings.
+	PingInterval time.Duration
+
+	// WriteTimeout maximum duration of writing a frame. If empty then 10s is used.
+	WriteTimeout time.Duration
+}
+
+// WSHandler handler of websocket connection, connection is closed when it returns.
+type WSHandler func(c *WSConn)
+
+// wsRegistry open websocket connections of server, closed when server shuts down.
+type wsRegistry struct {
+	mu    sync.Mutex
+	opts  *WSOpts
+	conns map[*WSConn]struct{}
+	once  sync.Once
+}
+
+// WS register websocket endpoint, middlewares run before the upgrade like for any GET route, e.g. auth.
+func (s *Server) WS(path string, handler WSHandler, middlewares ...Middleware) {
+	s.GET(path, s.wsHandler(handler), middlewares...)
+}
+
+// WS register websocket endpoint in the group, see Server.WS.
+func (g *Group) WS(path string, handler WSHandler, middlewares ...Middleware) {
+	g.GET(path, g.server.wsHandler(handler), middlewares...)
+}
+
+func (s *Server) wsHandler(handler WSHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, s.ws.opts)
+		if err != nil {
+			return
+		}
+		s.trackWS(c)
+		defer s.untrackWS(c)
+		defer c.Close(CloseNormal, "")
+		handler(c)
+	}
+}
+
+func (s *Server) trackWS(c *WSConn) {
+	s.ws.once.Do(func() {
+		go func() {
+			<-s.Done()
+			s.CloseWebSockets(CloseGoingAway, "server is shutting down")
+		}()
+	})
+	s.ws.mu.Lock()
+	if s.ws.conns == nil {
+		s.ws.conns = make(map[*WSConn]struct{})
+	}
+	s.ws.conns[c] = struct{}{}
+	s.ws.mu.Unlock()
+}
+
+func (s *Server) untrackWS(c *WSConn) {
+	s.ws.mu.Lock()
+	delete(s.ws.conns, c)
+	s.ws.mu.Unlock()
+}
+
+// WebSockets return number of open websocket connections.
+func (s *Server) WebSockets() int {
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	return len(s.ws.conns)
+}
+
+// CloseWebSockets send close frame to every open websocket connection and close it.
+// Called with CloseGoingAway when server shuts down.
+func (s *Server) CloseWebSockets(code int, reason string) {
+	s.ws.mu.Lock()
+	conns := make([]*WSConn, 0, len(s.ws.conns))
+	for c := range s.ws.conns {
+		conns = append(conns, c)
+	}
+	s.ws.mu.Unlock()
+	for _, c := range conns {
+		c.Close(code, reason)
+	}
+}
+
+// WSConn websocket connection, see RFC 6455.
+// ReadMessage must be called from one goroutine at a time, writes are safe to call concurrently.
+type WSConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	req         *http.Request
+	subprotocol string
+
+	maxMessageSize int64
+	pingInterval   time.Duration
+	writeTimeout   time.Duration
+
+	// compress whether permessage-deflate is negotiated, readDict holds previous messages if client keeps context.
+	compress        bool
+	contextTakeover bool
+	readDict        []byte
+
+	// writeMu serializes frames, closeSent is set once close frame is written.
+	writeMu   sync.Mutex
+	closeSent bool
+
+	closeOnce sync.Once
+	closeErr  *CloseError
+	done      chan struct{}
+}
+
+// Upgrade complete websocket handshake of r and take over the connection.
+// On failure error response is written and error returned.
+// @opts: can be nil, if nil then default is used.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts *WSOpts) (*WSConn, error) {
+	var o WSOpts
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxMessageSize <= 0 {
+		o.MaxMessageSize = defaultWSMaxMessageSize
+	}
+	if o.PingInterval == 0 {
+		o.PingInterval = defaultWSPingInterval
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = defaultWSWriteTimeout
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if rawKey, err := base64.StdEncoding.DecodeString(key); r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") ||
+		err != nil || len(rawKey) != 16 {
+		ResponseString(w, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+		return nil, ErrWSBadHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		ResponseString(w, http.StatusUpgradeRequired, http.StatusText(http.StatusUpgradeRequired))
+		return nil, ErrWSBadHandshake
+	}
+	checkOrigin := o.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		ResponseString(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return nil, ErrWSOriginDenied
+	}
+	hj, ok := hijackerOf(w)
+	if !ok {
+		ResponseString(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return nil, http.ErrNotSupported
+	}
+
+	c := &WSConn{
+		req:            r,
+		maxMessageSize: o.MaxMessageSize,
+		pingInterval:   o.PingInterval,
+		writeTimeout:   o.WriteTimeout,
+		done:           make(chan struct{}),
+	}
+	h := w.Header()
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", wsAccept(key))
+	if c.subprotocol = selectSubprotocol(r, o.Subprotocols); c.subprotocol != "" {
+		h.Set("Sec-WebSocket-Protocol", c.subprotocol)
+	}
+	if o.Compression {
+		if ext, clientNoContext, ok := negotiateDeflate(r.Header); ok {
+			c.compress, c.contextTakeover = true, !clientNoContext
+			h.Set("Sec-WebSocket-Extensions", ext)
+		}
+	}
+	// hijack before header is marked as written, so failure can still be answered.
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		ResponseString(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return nil, err
+	}
+	if rw, ok := getResponseWriter(w); ok {
+		rw.prepareHeader(http.StatusSwitchingProtocols)
+	}
+	c.conn, c.br = conn, brw.Reader
+	conn.SetDeadline(time.Time{})
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	h.Write(&buf)
+	buf.WriteString("\r\n")
+	conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetWriteDeadline(time.Time{})
+	if c.pingInterval > 0 {
+		go c.ping()
+	}
+	return c, nil
+}
+
+// hijackerOf return hijacker of w, looking through writers wrapping it with Unwrap.
+func hijackerOf(w http.ResponseWriter) (http.Hijacker, bool) {
+	for w != nil {
+		if hj, ok := w.(http.Hijacker); ok {
+			return hj, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return nil, false
+}
+
+// headerHasToken whether comma separated header contains token, case insensitive.
+func headerHasToken(h http.Header, name string, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Scheme, ClientScheme(r)) && strings.EqualFold(u.Host, ClientHost(r))
+}
+
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	var offered []string
+	for _, v := range r.Header["Sec-Websocket-Protocol"] {
+		for _, p := range strings.Split(v, ",") {
+			offered = append(offered, strings.TrimSpace(p))
+		}
+	}
+	for _, s := range supported {
+		for _, p := range offered {
+			if s == p {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// negotiateDeflate accept the first permessage-deflate offer the server can honor.
+// Server never keeps compression context, so messages it sends are compressed independently.
+func negotiateDeflate(h http.Header) (ext string, clientNoContext bool, ok bool) {
+	for _, v := range h["Sec-Websocket-Extensions"] {
+	offers:
+		for _, offer := range strings.Split(v, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != wsDeflateExtension {
+				continue
+			}
+			clientNoContext = false
+			for _, p := range params[1:] {
+				name, value := strings.TrimSpace(p), ""
+				if i := strings.Index(name, "="); i >= 0 {
+					name, value = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), `"`)
+				}
+				switch name {
+				case "server_no_context_takeover":
+				case "client_no_context_takeover":
+					clientNoContext = true
+				case "server_max_window_bits":
+					// flate always uses the full window.
+					if value != "15" {
+						continue offers
+					}
+				case "client_max_window_bits":
+				default:
+					continue offers
+				}
+			}
+			ext = wsDeflateExtension + "; server_no_context_takeover"
+			if clientNoContext {
+				ext += "; client_no_context_takeover"
+			}
+			return ext, clientNoContext, true
+		}
+	}
+	return "", false, false
+}
+
+// Request return the upgraded request, e.g. to read path params or values stored in its context by middlewares.
+func (c *WSConn) Request() *http.Request {
+	return c.req
+}
+
+// Subprotocol return negotiated subprotocol, empty if none.
+func (c *WSConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Done return channel closed when connection is closed.
+func (c *WSConn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *WSConn) ping() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.writeFrame(opPing, nil, false) != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// WriteMessage send message of TextMessage or BinaryMessage type.
+func (c *WSConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return ErrWSMessageType
+	}
+	if c.compress && len(data) >= wsCompressThreshold {
+		compressed, err := deflate(data)
+		if err != nil {
+			return err
+		}
+		return c.writeFrame(byte(messageType), compressed, true)
+	}
+	return c.writeFrame(byte(messageType), data, false)
+}
+
+// WriteJSON send v encoded with json codec as text message.
+func (c *WSConn) WriteJSON(v interface{}) error {
+	var buf bytes.Buffer
+	codec, _ := LookupCodec("application/json")
+	if err := codec.Encode(&buf, v); err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+// Ping send ping, peer answers with pong.
+func (c *WSConn) Ping(data []byte) error {
+	if len(data) > 125 {
+		return ErrWSMessageType
+	}
+	return c.writeFrame(opPing, data, false)
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), wsFlushTail), nil
+}
+
+func (c *WSConn) writeFrame(opcode byte, payload []byte, compressed bool) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrWSClosed
+	}
+	var header [10]byte
+	header[0] = 0x80 | opcode
+	if compressed {
+		header[0] |= 0x40
+	}
+	n := 2
+	switch l := len(payload); {
+	case l < 126:
+		header[1] = byte(l)
+	case l <= 0xffff:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(l))
+		n = 4
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(l))
+		n = 10
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	buffers := net.Buffers{header[:n], payload}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
+
+// Close send close frame with code and reason, then close the connection. Subsequent calls are ignored.
+func (c *WSConn) Close(code int, reason string) error {
+	return c.closeWith(&CloseError{Code: code, Reason: reason}, true)
+}
+
+func (c *WSConn) closeWith(ce *CloseError, send bool) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.writeMu.Lock()
+		c.closeErr = ce
+		c.writeMu.Unlock()
+		if send {
+			var payload []byte
+			if ce.Code != CloseNoStatus && ce.Code != CloseAbnormal {
+				payload = make([]byte, 2, 2+len(ce.Reason))
+				binary.BigEndian.PutUint16(payload, uint16(ce.Code))
+				payload = append(payload, ce.Reason...)
+				if len(payload) > 125 {
+					payload = payload[:125]
+				}
+			}
+			c.writeFrame(opClose, payload, false)
+		}
+		err = c.conn.Close()
+		close(c.done)
+	})
+	return err
+}
+
+// fail close connection because of peer misbehaving, and return the reason.
+func (c *WSConn) fail(code int, reason string) error {
+	c.closeWith(&CloseError{Code: code, Reason: reason}, true)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  byte
+	payload []byte
+}
+
+func (c *WSConn) readFrame() (*wsFrame, error) {
+	if c.pingInterval > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+	}
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return nil, err
+	}
+	f := &wsFrame{fin: head[0]&0x80 != 0, rsv1: head[0]&0x40 != 0, opcode: head[0] & 0x0f}
+	if head[0]&0x30 != 0 {
+		return nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return nil, c.fail(CloseProtocolError, "client frame not masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if f.opcode >= opClose && (!f.fin || length > 125) {
+		return nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length > uint64(c.maxMessageSize) {
+		return nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return nil, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return nil, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// ReadMessage return the next data message, answering pings and close frames on the way.
+// Return *CloseError once connection is closed by either side.
+func (c *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	var (
+		compressed bool
+		started    bool
+	)
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.readError(err)
+		}
+		switch f.opcode {
+		case opPing:
+			c.writeFrame(opPong, f.payload, false)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.peerClose(f.payload)
+		case opContinuation:
+			if !started || f.rsv1 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case opText, opBinary:
+			if started {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			if f.rsv1 && !c.compress {
+				return 0, nil, c.fail(CloseProtocolError, "compression not negotiated")
+			}
+			started, compressed, messageType = true, f.rsv1, int(f.opcode)
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+		if int64(len(data)+len(f.payload)) > c.maxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		data = append(data, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+	if compressed {
+		if data, err = c.inflate(data); err != nil {
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+	}
+	return messageType, data, nil
+}
+
+// ReadJSON read the next message and decode it with json codec into v.
+func (c *WSConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	codec, _ := LookupCodec("application/json")
+	return codec.Decode(bytes.NewReader(data), v)
+}
+
+// readError return close reason if connection has been closed, otherwise err.
+func (c *WSConn) readError(err error) error {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		return err
+	}
+	c.writeMu.Lock()
+	closeErr := c.closeErr
+	c.writeMu.Unlock()
+	if closeErr != nil {
+		return closeErr
+	}
+	c.closeWith(&CloseError{Code: CloseAbnormal, Reason: err.Error()}, false)
+	return &CloseError{Code: CloseAbnormal, Reason: err.Error()}
+}
+
+// peerClose answer close frame of the peer with the same code.
+func (c *WSConn) peerClose(payload []byte) error {
+	ce := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		ce.Code, ce.Reason = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+		if !validCloseCode(ce.Code) || !utf8.ValidString(ce.Reason) {
+			return c.fail(CloseProtocolError, "invalid close frame")
+		}
+	}
+	c.closeOnce.Do(func() {
+		c.writeMu.Lock()
+		c.closeErr = ce
+		c.writeMu.Unlock()
+		var reply []byte
+		if ce.Code != CloseNoStatus {
+			reply = make([]byte, 2)
+			binary.BigEndian.PutUint16(reply, uint16(ce.Code))
+		}
+		c.writeFrame(opClose, reply, false)
+		c.conn.Close()
+		close(c.done)
+	})
+	return ce
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011, code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// inflate decompress message, using previous messages as dictionary if client keeps compression context.
+func (c *WSConn) inflate(data []byte) ([]byte, error) {
+	fr := flate.NewReaderDict(io.MultiReader(bytes.NewReader(data), bytes.NewReader(wsFlushTail)), c.readDict)
+	defer fr.Close()
+	out, err := ioutil.ReadAll(io.LimitReader(fr, c.maxMessageSize+1))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, c.fail(CloseInvalidPayload, "invalid compressed data")
+	}
+	if int64(len(out)) > c.maxMessageSize {
+		return nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+	if c.contextTakeover {
+		c.readDict = append(c.readDict, out...)
+		if len(c.readDict) > wsWindowSize {
+			c.readDict = append([]byte(nil), c.readDict[len(c.readDict)-wsWindowSize:]...)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,295 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWSKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dialWS(t *testing.T, url string, header http.Header) *wsTestClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("%s expected no error, returned %v", t.Name(), err)
+	}
+	req, _ := http.NewRequest("GET", url+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWSKey)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Write(conn)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("%s expected handshake response, returned %v", t.Name(), err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &wsTestClient{t: t, conn: conn, br: br, resp: resp}
+}
+
+func (c *wsTestClient) write(b0 byte, payload []byte) {
+	frame := []byte{b0}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	c.conn.Write(frame)
+}
+
+func (c *wsTestClient) read() (byte, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		c.t.Fatalf("%s expected frame, returned %v", c.t.Name(), err)
+	}
+	length := int(head[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	io.ReadFull(c.br, payload)
+	return head[0], payload
+}
+
+func newWSTestServer(opts *WSOpts, handler WSHandler, middlewares ...Middleware) (*Server, *httptest.Server) {
+	srv := New(&Opts{WebSocket: opts})
+	srv.WS("/ws", handler, middlewares...)
+	srv.build()
+	return srv, httptest.NewServer(srv.handlers)
+}
+
+func echo(c *WSConn) {
+	for {
+		mt, data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.WriteMessage(mt, data)
+	}
+}
+
+func TestWS_Handshake(t *testing.T) {
+	var called bool
+	mw := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			next(w, r)
+		}
+	}
+	_, ts := newWSTestServer(&WSOpts{Subprotocols: []string{"v2", "v1"}}, echo, mw)
+	defer ts.Close()
+
+	c := dialWS(t, ts.URL, http.Header{"Sec-Websocket-Protocol": {"v1, v2"}})
+	defer c.conn.Close()
+	if c.resp.StatusCode != http.StatusSwitchingProtocols || !called {
+		t.Fatalf("%s expected 101 after middleware, returned %d", t.Name(), c.resp.StatusCode)
+	}
+	if accept := c.resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("%s expected accept key from RFC 6455, returned %s", t.Name(), accept)
+	}
+	if p := c.resp.Header.Get("Sec-WebSocket-Protocol"); p != "v2" {
+		t.Errorf("%s expected server preferred subprotocol v2, returned %s", t.Name(), p)
+	}
+	if c.resp.Header.Get("Request-Id") == "" {
+		t.Errorf("%s expected request id header on handshake", t.Name())
+	}
+
+	c.write(0x01, []byte("hel"))
+	c.write(0x89, []byte("p"))
+	c.write(0x80, []byte("lo"))
+	if b0, payload := c.read(); b0 != 0x8a || string(payload) != "p" {
+		t.Errorf("%s expected pong p, returned %x %q", t.Name(), b0, payload)
+	}
+	if b0, payload := c.read(); b0 != 0x81 || string(payload) != "hello" {
+		t.Errorf("%s expected text hello, returned %x %q", t.Name(), b0, payload)
+	}
+
+	c.write(0x88, []byte{0x03, 0xe8})
+	if b0, payload := c.read(); b0 != 0x88 || binary.BigEndian.Uint16(payload) != CloseNormal {
+		t.Errorf("%s expected echoed close 1000, returned %x %v", t.Name(), b0, payload)
+	}
+}
+
+func TestWS_Rejected(t *testing.T) {
+	_, ts := newWSTestServer(nil, echo)
+	defer ts.Close()
+	tests := []struct {
+		header http.Header
+		status int
+	}{
+		{http.Header{"Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{http.Header{"Sec-Websocket-Key": {"short"}}, http.StatusBadRequest},
+		{http.Header{"Origin": {"http://evil.example"}}, http.StatusForbidden},
+		{http.Header{"Origin": {"https://" + strings.TrimPrefix(ts.URL, "http://")}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		c := dialWS(t, ts.URL, tt.header)
+		if c.resp.StatusCode != tt.status {
+			t.Errorf("%s expected %d for %v, returned %d", t.Name(), tt.status, tt.header, c.resp.StatusCode)
+		}
+		c.conn.Close()
+	}
+}
+
+func TestWS_Limits(t *testing.T) {
+	_, ts := newWSTestServer(&WSOpts{MaxMessageSize: 4}, echo)
+	defer ts.Close()
+	tests := []struct {
+		b0      byte
+		payload []byte
+		code    uint16
+	}{
+		{0x81, []byte("too long"), CloseMessageTooBig},
+		{0x81, []byte{0xff}, CloseInvalidPayload},
+		{0xc1, []byte("x"), CloseProtocolError},
+		{0x80, []byte("x"), CloseProtocolError},
+	}
+	for _, tt := range tests {
+		c := dialWS(t, ts.URL, nil)
+		c.write(tt.b0, tt.payload)
+		if b0, payload := c.read(); b0 != 0x88 || binary.BigEndian.Uint16(payload) != tt.code {
+			t.Errorf("%s expected close %d for %x %q, returned %x %v", t.Name(), tt.code, tt.b0, tt.payload, b0, payload)
+		}
+		c.conn.Close()
+	}
+}
+
+func TestWS_Compression(t *testing.T) {
+	_, ts := newWSTestServer(&WSOpts{Compression: true}, echo)
+	defer ts.Close()
+	c := dialWS(t, ts.URL, http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}})
+	defer c.conn.Close()
+	if ext := c.resp.Header.Get("Sec-WebSocket-Extensions"); ext != "permessage-deflate; server_no_context_takeover" {
+		t.Fatalf("%s expected permessage-deflate, returned %q", t.Name(), ext)
+	}
+
+	// client keeps its compression context, the second message refers to the first one.
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	message := strings.Repeat("websocket ", 10)
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		fw.Write([]byte(message))
+		fw.Flush()
+		c.write(0xc1, bytes.TrimSuffix(buf.Bytes(), wsFlushTail))
+
+		b0, payload := c.read()
+		if b0 != 0xc1 {
+			t.Fatalf("%s expected compressed text frame, returned %x", t.Name(), b0)
+		}
+		out, _ := ioutil.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(wsFlushTail))))
+		if string(out) != message {
+			t.Errorf("%s expected %q, returned %q", t.Name(), message, out)
+		}
+	}
+}
+
+func TestWS_Shutdown(t *testing.T) {
+	srv, ts := newWSTestServer(nil, func(c *WSConn) {
+		c.WriteJSON(map[string]string{"hello": c.Request().URL.Path})
+		echo(c)
+	})
+	defer ts.Close()
+	c := dialWS(t, ts.URL, nil)
+	defer c.conn.Close()
+	if _, payload := c.read(); string(payload) != `{"hello":"/ws"}` {
+		t.Errorf("%s expected json message, returned %s", t.Name(), payload)
+	}
+	if srv.WebSockets() != 1 {
+		t.Errorf("%s expected 1 open websocket, returned %d", t.Name(), srv.WebSockets())
+	}
+
+	srv.shutdown.close()
+	if b0, payload := c.read(); b0 != 0x88 || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+		t.Errorf("%s expected close 1001 on shutdown, returned %x %v", t.Name(), b0, payload)
+	}
+	for i := 0; srv.WebSockets() != 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if srv.WebSockets() != 0 {
+		t.Errorf("%s expected no open websockets, returned %d", t.Name(), srv.WebSockets())
+	}
+}
+
+type failingHijacker struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+func TestWS_Compress(t *testing.T) {
+	srv := New(&Opts{})
+	srv.Use(Compress(nil))
+	srv.WS("/ws", echo)
+	srv.build()
+	ts := httptest.NewServer(srv.handlers)
+	defer ts.Close()
+
+	c := dialWS(t, ts.URL, http.Header{"Accept-Encoding": {"gzip"}})
+	defer c.conn.Close()
+	if c.resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("%s expected 101 behind compression, returned %d", t.Name(), c.resp.StatusCode)
+	}
+	c.write(0x81, []byte("hi"))
+	if _, payload := c.read(); string(payload) != "hi" {
+		t.Errorf("%s expected echo, returned %q", t.Name(), payload)
+	}
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", testWSKey)
+	w := httptest.NewRecorder()
+	if _, err := Upgrade(wrapResponseWriter(newResponseWriter(failingHijacker{w}, "", "")), r, nil); err == nil || w.Code != http.StatusInternalServerError {
+		t.Errorf("%s expected 500 when hijack fails, returned %d %v", t.Name(), w.Code, err)
+	}
+}
+
+func TestWS_ProxyOrigin(t *testing.T) {
+	srv := New(&Opts{TrustedProxies: []string{"127.0.0.0/8", "::1/128"}})
+	srv.WS("/ws", echo)
+	srv.build()
+	ts := httptest.NewServer(srv.handlers)
+	defer ts.Close()
+
+	c := dialWS(t, ts.URL, http.Header{
+		"Origin":            {"https://www.example.com"},
+		"X-Forwarded-Host":  {"www.example.com"},
+		"X-Forwarded-Proto": {"https"},
+	})
+	defer c.conn.Close()
+	if c.resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("%s expected origin of forwarded host allowed, returned %d", t.Name(), c.resp.StatusCode)
+	}
+}
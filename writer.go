@@ -0,0 +1,208 @@
+package httpserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// wrapResponseWriter expose exactly the optional interfaces, http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom,
+// implemented by the writer rw wraps, so type assertions by handlers behave as if there were no wrapper.
+func wrapResponseWriter(rw *responseWriter) http.ResponseWriter {
+	var mask int
+	if _, ok := rw.ResponseWriter.(http.Flusher); ok {
+		mask |= 1
+	}
+	if _, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		mask |= 2
+	}
+	if _, ok := rw.ResponseWriter.(http.Pusher); ok {
+		mask |= 4
+	}
+	if _, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		mask |= 8
+	}
+	fl, hj, ps, rf := &flusher{rw}, &hijacker{rw}, &pusher{rw}, &readerFrom{rw}
+	switch mask {
+	case 1:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{rw, fl}
+	case 2:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{rw, hj}
+	case 3:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, fl, hj}
+	case 4:
+		return struct {
+			*responseWriter
+			http.Pusher
+		}{rw, ps}
+	case 5:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+		}{rw, fl, ps}
+	case 6:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+		}{rw, hj, ps}
+	case 7:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{rw, fl, hj, ps}
+	case 8:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{rw, rf}
+	case 9:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{rw, fl, rf}
+	case 10:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, hj, rf}
+	case 11:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, fl, hj, rf}
+	case 12:
+		return struct {
+			*responseWriter
+			http.Pusher
+			io.ReaderFrom
+		}{rw, ps, rf}
+	case 13:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{rw, fl, ps, rf}
+	case 14:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, hj, ps, rf}
+	case 15:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, fl, hj, ps, rf}
+	}
+	return rw
+}
+
+// optional interfaces look up the current underlying writer on every call, since middlewares such as Compress
+// may swap it during the request.
+
+type flusher struct {
+	rw *responseWriter
+}
+
+func (f *flusher) Flush() {
+	if !f.rw.wroteHeader {
+		f.rw.WriteHeader(http.StatusOK)
+	}
+	if fl, ok := f.rw.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+type hijacker struct {
+	rw *responseWriter
+}
+
+func (h *hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := h.rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, brw, err := hj.Hijack()
+	if err == nil {
+		h.rw.hijacked = true
+	}
+	return conn, brw, err
+}
+
+type pusher struct {
+	rw *responseWriter
+}
+
+func (p *pusher) Push(target string, opts *http.PushOptions) error {
+	ps, ok := p.rw.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return ps.Push(target, opts)
+}
+
+type readerFrom struct {
+	rw *responseWriter
+}
+
+func (rf *readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	rw := rf.rw
+	if rw.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	r, ok := rw.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		// *responseWriter has no ReadFrom, so io.Copy goes through Write.
+		return io.Copy(rw, src)
+	}
+	n, err := r.ReadFrom(src)
+	rw.written += n
+	return n, err
+}
+
+// BytesWritten return number of response body bytes written so far.
+// Return 0 if w doesn't come from handler served by this package.
+func BytesWritten(w http.ResponseWriter) int64 {
+	rw, ok := getResponseWriter(w)
+	if !ok {
+		return 0
+	}
+	return rw.written
+}
+
+// HeaderWritten whether response header has been sent to client, after which status and headers can't be changed anymore.
+// Return false if w doesn't come from handler served by this package.
+func HeaderWritten(w http.ResponseWriter) bool {
+	rw, ok := getResponseWriter(w)
+	if !ok {
+		return false
+	}
+	return rw.wroteHeader || rw.hijacked
+}
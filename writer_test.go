@@ -0,0 +1,107 @@
+package httpserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testHijackWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	readFrom bool
+}
+
+func (tw *testHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.hijacked = true
+	return nil, nil, nil
+}
+
+func (tw *testHijackWriter) ReadFrom(src io.Reader) (int64, error) {
+	tw.readFrom = true
+	return io.Copy(tw.ResponseRecorder, src)
+}
+
+func TestWrapResponseWriter_Flusher(t *testing.T) {
+	var flushed bool
+	next := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			t.Errorf("%s expected writer not to be http.Hijacker", t.Name())
+		}
+		if _, ok := w.(io.ReaderFrom); ok {
+			t.Errorf("%s expected writer not to be io.ReaderFrom", t.Name())
+		}
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatalf("%s expected writer to be http.Flusher", t.Name())
+		}
+		f.Flush()
+		flushed = true
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/flush", nil)
+	f(next, nil)(w, r, nil)
+	if !flushed || !w.Flushed {
+		t.Errorf("%s expected response flushed", t.Name())
+	}
+	if w.Header().Get("Request-Id") == "" {
+		t.Errorf("%s expected Request-Id header sent before flush", t.Name())
+	}
+}
+
+func TestWrapResponseWriter_HijackerReaderFrom(t *testing.T) {
+	tw := &testHijackWriter{ResponseRecorder: httptest.NewRecorder()}
+	var written int64
+	next := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Pusher); ok {
+			t.Errorf("%s expected writer not to be http.Pusher", t.Name())
+		}
+		rf, ok := w.(io.ReaderFrom)
+		if !ok {
+			t.Fatalf("%s expected writer to be io.ReaderFrom", t.Name())
+		}
+		rf.ReadFrom(strings.NewReader("hello"))
+		written = BytesWritten(w)
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Errorf("%s expected null error, returned %v", t.Name(), err)
+		}
+		if _, err := w.Write([]byte("x")); err != http.ErrHijacked {
+			t.Errorf("%s expected %v, returned %v", t.Name(), http.ErrHijacked, err)
+		}
+	}
+	r, _ := http.NewRequest("GET", "/hijack", nil)
+	f(next, nil)(tw, r, nil)
+	if !tw.readFrom || !tw.hijacked {
+		t.Errorf("%s expected ReadFrom and Hijack delegated, returned %t and %t", t.Name(), tw.readFrom, tw.hijacked)
+	}
+	if written != 5 {
+		t.Errorf("%s expected 5 bytes written, returned %d", t.Name(), written)
+	}
+}
+
+func TestHeaderWritten(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		if HeaderWritten(w) {
+			t.Errorf("%s expected header not written yet", t.Name())
+		}
+		ResponseString(w, http.StatusAccepted, "test")
+		if !HeaderWritten(w) {
+			t.Errorf("%s expected header written", t.Name())
+		}
+		if n := BytesWritten(w); n != 4 {
+			t.Errorf("%s expected 4 bytes written, returned %d", t.Name(), n)
+		}
+		panic("after write")
+	}
+	srv := New(&Opts{})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/written", nil)
+	f(srv.recoverPanic(next), nil)(w, r, nil)
+	if w.Code != http.StatusAccepted || w.Body.String() != "test" {
+		t.Errorf("%s expected %d test, returned %d %s", t.Name(), http.StatusAccepted, w.Code, w.Body.String())
+	}
+}